@@ -0,0 +1,111 @@
+// Blueprint definition, loading and validation utilities.
+package blueprint
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/resonance/resources"
+)
+
+// Entry is a single resource declared at a Blueprint.
+type Entry struct {
+	// TypeName is the name of the resource type, eg: File.
+	TypeName string
+	// Resource holds the resource definition.
+	Resource resources.Resource
+	// Path of the file where the resource was declared.
+	Path string
+	// Line at Path where the resource was declared.
+	Line int
+}
+
+// Source returns where the Entry was declared, in the format "path:line". Entries not declared at
+// a file (eg: loaded from a store) return the resource ID instead.
+func (e *Entry) Source() string {
+	if e.Path == "" {
+		return e.Resource.ID()
+	}
+	return fmt.Sprintf("%s:%d", e.Path, e.Line)
+}
+
+// Validate the Entry resource, annotating errors with where it was declared.
+func (e *Entry) Validate() error {
+	if err := e.Resource.Validate(); err != nil {
+		return fmt.Errorf("%s: %s: %w", e.Source(), e.TypeName, err)
+	}
+	return nil
+}
+
+// Blueprint holds a collection of resources, in the same order they were declared.
+type Blueprint struct {
+	Entries []*Entry
+}
+
+// Get returns the Entry with given type name and resource id, or nil if not declared.
+func (b *Blueprint) Get(typeName, id string) *Entry {
+	for _, entry := range b.Entries {
+		if entry.TypeName == typeName && entry.Resource.ID() == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Subtract returns a new Blueprint with all entries from b which are not declared at other.
+func (b *Blueprint) Subtract(other *Blueprint) *Blueprint {
+	blueprint := &Blueprint{}
+	for _, entry := range b.Entries {
+		if other.Get(entry.TypeName, entry.Resource.ID()) == nil {
+			blueprint.Entries = append(blueprint.Entries, entry)
+		}
+	}
+	return blueprint
+}
+
+// Validate all entries, returning all errors found. Each resource can only be declared once.
+func (b *Blueprint) Validate() error {
+	var errs []error
+	entryMap := map[string]map[string]*Entry{}
+	for _, entry := range b.Entries {
+		if err := entry.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := entryMap[entry.TypeName]; !ok {
+			entryMap[entry.TypeName] = map[string]*Entry{}
+		}
+		if previousEntry, ok := entryMap[entry.TypeName][entry.Resource.ID()]; ok {
+			errs = append(errs, fmt.Errorf(
+				"%s: %s: %#v already declared at %s",
+				entry.Source(), entry.TypeName, entry.Resource.ID(), previousEntry.Source(),
+			))
+			continue
+		}
+		entryMap[entry.TypeName][entry.Resource.ID()] = entry
+	}
+	return errors.Join(errs...)
+}
+
+// MarshalYAML marshals the Blueprint in the same format it is loaded from: a list of resources,
+// each keyed by its type name.
+func (b *Blueprint) MarshalYAML() (any, error) {
+	entries := []map[string]resources.Resource{}
+	for _, entry := range b.Entries {
+		entries = append(entries, map[string]resources.Resource{entry.TypeName: entry.Resource})
+	}
+	return entries, nil
+}
+
+// Checksum returns a SHA-256 checksum of the Blueprint, as marshaled by MarshalYAML. It only
+// depends on the declared resources, not on where they were declared.
+func (b *Blueprint) Checksum() (string, error) {
+	blueprintBytes, err := yaml.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(blueprintBytes)), nil
+}
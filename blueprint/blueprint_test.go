@@ -0,0 +1,49 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/resonance/resources"
+)
+
+func TestBlueprint(t *testing.T) {
+	vim := &Entry{TypeName: "APTPackage", Resource: &resources.APTPackage{Package: "vim"}}
+	curl := &Entry{TypeName: "APTPackage", Resource: &resources.APTPackage{Package: "curl"}}
+	file := &Entry{TypeName: "File", Resource: &resources.File{Path: "/vim"}}
+	blueprint := &Blueprint{Entries: []*Entry{vim, curl, file}}
+
+	t.Run("Get()", func(t *testing.T) {
+		require.Equal(t, vim, blueprint.Get("APTPackage", "vim"))
+		require.Equal(t, file, blueprint.Get("File", "/vim"))
+		require.Nil(t, blueprint.Get("File", "vim"))
+	})
+
+	t.Run("Subtract()", func(t *testing.T) {
+		require.Equal(t, []*Entry{vim, file}, blueprint.Subtract(&Blueprint{Entries: []*Entry{curl}}).Entries)
+		require.Empty(t, blueprint.Subtract(blueprint).Entries)
+	})
+
+	t.Run("Checksum()", func(t *testing.T) {
+		checksum, err := blueprint.Checksum()
+		require.NoError(t, err)
+		require.Regexp(t, `^sha256:[0-9a-f]{64}$`, checksum)
+
+		declaredElsewhere := &Blueprint{Entries: []*Entry{
+			vim, curl, {TypeName: "File", Resource: file.Resource, Path: "test.yaml", Line: 3},
+		}}
+		otherChecksum, err := declaredElsewhere.Checksum()
+		require.NoError(t, err)
+		require.Equal(t, checksum, otherChecksum)
+
+		otherChecksum, err = blueprint.Subtract(&Blueprint{Entries: []*Entry{curl}}).Checksum()
+		require.NoError(t, err)
+		require.NotEqual(t, checksum, otherChecksum)
+	})
+
+	t.Run("Entry.Source()", func(t *testing.T) {
+		require.Equal(t, "vim", vim.Source())
+		require.Equal(t, "test.yaml:3", (&Entry{Resource: vim.Resource, Path: "test.yaml", Line: 3}).Source())
+	})
+}
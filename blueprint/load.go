@@ -0,0 +1,250 @@
+package blueprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/resources"
+)
+
+// checkKnownFields returns an error for each mapping key at node which does not match a field
+// of given type. This is required, as yaml.Node.Decode has no equivalent to
+// yaml.Decoder.KnownFields.
+func checkKnownFields(path string, node *yaml.Node, t reflect.Type) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	errs := []error{}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fieldTypes := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldTypes[name] = field.Type
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			valueNode := node.Content[i+1]
+			fieldType, ok := fieldTypes[keyNode.Value]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s:%d: unknown field %#v", path, keyNode.Line, keyNode.Value))
+				continue
+			}
+			errs = append(errs, checkKnownFields(path, valueNode, fieldType)...)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, itemNode := range node.Content {
+			errs = append(errs, checkKnownFields(path, itemNode, t.Elem())...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, checkKnownFields(path, node.Content[i], t.Elem())...)
+		}
+	}
+	return errs
+}
+
+func loadEntry(path string, node *yaml.Node) (*Entry, []error) {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return nil, []error{fmt.Errorf(
+			"%s:%d: expected a single resource type name key, valid types are: %s",
+			path, node.Line, strings.Join(resources.ResourceTypeNames(), ", "),
+		)}
+	}
+	keyNode := node.Content[0]
+	valueNode := node.Content[1]
+
+	resourceType := resources.GetResourceType(keyNode.Value)
+	if resourceType == nil {
+		return nil, []error{fmt.Errorf(
+			"%s:%d: unknown resource type %#v, valid types are: %s",
+			path, keyNode.Line, keyNode.Value, strings.Join(resources.ResourceTypeNames(), ", "),
+		)}
+	}
+	resource := resourceType.New("")
+
+	if errs := checkKnownFields(path, valueNode, reflect.TypeOf(resource)); len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := valueNode.Decode(resource); err != nil {
+		return nil, []error{fmt.Errorf("%s: %s: %w", path, keyNode.Value, err)}
+	}
+
+	return &Entry{
+		TypeName: keyNode.Value,
+		Resource: resource,
+		Path:     path,
+		Line:     keyNode.Line,
+	}, nil
+}
+
+func loadEntries(path string, node *yaml.Node) ([]*Entry, []error) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, []error{fmt.Errorf("%s:%d: expected a list of resources", path, node.Line)}
+	}
+
+	entries := []*Entry{}
+	errs := []error{}
+	for _, itemNode := range node.Content {
+		entry, entryErrs := loadEntry(path, itemNode)
+		if len(entryErrs) > 0 {
+			errs = append(errs, entryErrs...)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, errs
+}
+
+func loadDocument(path string, node *yaml.Node) ([]*Entry, []error) {
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	return loadEntries(path, node.Content[0])
+}
+
+// UnmarshalYAML unmarshals a Blueprint from the same format it is loaded from. Unlike Load,
+// resources are not validated, as this is also used for the state loaded from hosts.
+func (b *Blueprint) UnmarshalYAML(node *yaml.Node) error {
+	entries, errs := loadEntries("", node)
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	b.Entries = entries
+	return nil
+}
+
+// Load a Blueprint from given reader, with YAML documents, each with a list of resources. Path is
+// only used to annotate errors. All resources are validated, and every error found is returned.
+func Load(ctx context.Context, path string, reader io.Reader) (*Blueprint, error) {
+	log.MustLogger(ctx).Debug("Loading", "path", path)
+
+	blueprint := &Blueprint{}
+	errs := []error{}
+
+	decoder := yaml.NewDecoder(reader)
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			break
+		}
+		entries, documentErrs := loadDocument(path, &node)
+		blueprint.Entries = append(blueprint.Entries, entries...)
+		errs = append(errs, documentErrs...)
+	}
+
+	if err := blueprint.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return blueprint, nil
+}
+
+func loadFile(ctx context.Context, path string) (_ *Blueprint, retErr error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { retErr = errors.Join(retErr, file.Close()) }()
+	return Load(ctx, path, file)
+}
+
+func getYamlPaths(path string) ([]string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fileInfo.IsDir() {
+		return []string{path}, nil
+	}
+
+	paths := []string{}
+	if err := filepath.WalkDir(path, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if dirEntry.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml":
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// LoadPath loads a Blueprint from given path, which can be either a YAML file, or a directory,
+// which is walked in lexical order for *.yaml and *.yml files. Resources are kept in the same order
+// they were declared. All resources are validated, and every error found is returned.
+func LoadPath(ctx context.Context, path string) (*Blueprint, error) {
+	paths, err := getYamlPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	blueprint := &Blueprint{}
+	errs := []error{}
+	for _, path := range paths {
+		fileBlueprint, err := loadFile(ctx, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		blueprint.Entries = append(blueprint.Entries, fileBlueprint.Entries...)
+	}
+	// Resources may be declared more than once across files
+	if err := blueprint.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return blueprint, nil
+}
@@ -0,0 +1,274 @@
+package blueprint
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host/types"
+	"github.com/fornellas/resonance/resources"
+)
+
+func TestLoad(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+
+	t.Run("valid", func(t *testing.T) {
+		blueprint, err := Load(ctx, "test.yaml", strings.NewReader(`
+- File:
+    path: /etc/hosts
+    regular_file: "127.0.0.1 localhost\n"
+    mode: 0644
+- APTPackage:
+    package: vim
+---
+- DpkgArch:
+    foreign_architectures: [i386]
+`))
+		require.NoError(t, err)
+
+		contents := "127.0.0.1 localhost\n"
+		var mode types.FileMode = 0644
+		require.Equal(t, &Blueprint{
+			Entries: []*Entry{
+				{
+					TypeName: "File",
+					Resource: &resources.File{
+						Path:        "/etc/hosts",
+						RegularFile: &contents,
+						Mode:        &mode,
+					},
+					Path: "test.yaml",
+					Line: 2,
+				},
+				{
+					TypeName: "APTPackage",
+					Resource: &resources.APTPackage{
+						Package: "vim",
+					},
+					Path: "test.yaml",
+					Line: 6,
+				},
+				{
+					TypeName: "DpkgArch",
+					Resource: &resources.DpkgArch{
+						ForeignArchitectures: []string{"i386"},
+					},
+					Path: "test.yaml",
+					Line: 9,
+				},
+			},
+		}, blueprint)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		blueprint, err := Load(ctx, "test.yaml", strings.NewReader("# nothing here\n"))
+		require.NoError(t, err)
+		require.Empty(t, blueprint.Entries)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			name          string
+			yaml          string
+			errorContains []string
+		}{
+			{
+				name:          "syntax",
+				yaml:          "- File: [\n",
+				errorContains: []string{"test.yaml: yaml: line"},
+			},
+			{
+				name:          "not a list",
+				yaml:          "File:\n  path: /foo\n",
+				errorContains: []string{"test.yaml:1: expected a list of resources"},
+			},
+			{
+				name:          "unknown type",
+				yaml:          "- Foo:\n    bar: baz\n",
+				errorContains: []string{`test.yaml:1: unknown resource type "Foo"`},
+			},
+			{
+				name:          "multiple types",
+				yaml:          "- File:\n    path: /foo\n  APTPackage:\n    package: vim\n",
+				errorContains: []string{"test.yaml:1: expected a single resource type name key"},
+			},
+			{
+				name: "unknown field",
+				yaml: "- File:\n    path: /foo\n    absent: true\n    directory:\n      - path: /foo/bar\n        bad: true\n",
+				errorContains: []string{
+					`test.yaml:6: unknown field "bad"`,
+				},
+			},
+			{
+				name:          "bad type",
+				yaml:          "- File:\n    path: /foo\n    uid: foo\n",
+				errorContains: []string{"test.yaml: File: yaml: unmarshal errors:", "line 3:"},
+			},
+			{
+				name:          "duplicated",
+				yaml:          "- APTPackage:\n    package: vim\n- File:\n    path: /vim\n    absent: true\n- APTPackage:\n    package: vim\n",
+				errorContains: []string{`test.yaml:6: APTPackage: "vim" already declared at test.yaml:1`},
+			},
+			{
+				name: "every validation error",
+				yaml: "- File:\n    path: foo\n    absent: true\n- APTPackage:\n    package: vim\n- APTPackage:\n    package: '!'\n",
+				errorContains: []string{
+					"test.yaml:1: File: 'path' must be absolute",
+					"test.yaml:6: APTPackage: invalid package",
+				},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := Load(ctx, "test.yaml", strings.NewReader(tc.yaml))
+				require.Error(t, err)
+				for _, errorContains := range tc.errorContains {
+					require.ErrorContains(t, err, errorContains)
+				}
+			})
+		}
+	})
+}
+
+func TestLoadPath(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "b"), 0700))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "b", "packages.yml"),
+		[]byte("- APTPackage:\n    package: vim\n"),
+		0600,
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "a.yaml"),
+		[]byte("- APTPackage:\n    package: curl\n"),
+		0600,
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "README.md"),
+		[]byte("not a blueprint"),
+		0600,
+	))
+
+	t.Run("dir", func(t *testing.T) {
+		blueprint, err := LoadPath(ctx, dir)
+		require.NoError(t, err)
+		require.Len(t, blueprint.Entries, 2)
+		require.Equal(t, &resources.APTPackage{Package: "curl"}, blueprint.Entries[0].Resource)
+		require.Equal(t, filepath.Join(dir, "a.yaml"), blueprint.Entries[0].Path)
+		require.Equal(t, &resources.APTPackage{Package: "vim"}, blueprint.Entries[1].Resource)
+		require.Equal(t, filepath.Join(dir, "b", "packages.yml"), blueprint.Entries[1].Path)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		blueprint, err := LoadPath(ctx, filepath.Join(dir, "a.yaml"))
+		require.NoError(t, err)
+		require.Len(t, blueprint.Entries, 1)
+	})
+
+	t.Run("errors from all files", func(t *testing.T) {
+		badDir := t.TempDir()
+		require.NoError(t, os.WriteFile(
+			filepath.Join(badDir, "a.yaml"), []byte("- APTPackage:\n    package: '!'\n"), 0600,
+		))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(badDir, "b.yaml"), []byte("- File:\n    path: foo\n    absent: true\n"), 0600,
+		))
+		_, err := LoadPath(ctx, badDir)
+		require.ErrorContains(t, err, filepath.Join(badDir, "a.yaml")+":1: APTPackage: invalid package")
+		require.ErrorContains(t, err, filepath.Join(badDir, "b.yaml")+":1: File: 'path' must be absolute")
+	})
+
+	t.Run("declared at multiple files", func(t *testing.T) {
+		duplicateDir := t.TempDir()
+		require.NoError(t, os.WriteFile(
+			filepath.Join(duplicateDir, "a.yaml"), []byte("- APTPackage:\n    package: vim\n"), 0600,
+		))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(duplicateDir, "b.yaml"),
+			[]byte("- APTPackage:\n    package: curl\n- APTPackage:\n    package: vim\n"),
+			0600,
+		))
+		_, err := LoadPath(ctx, duplicateDir)
+		require.EqualError(t, err, fmt.Sprintf(
+			"%s:3: APTPackage: \"vim\" already declared at %s:1",
+			filepath.Join(duplicateDir, "b.yaml"), filepath.Join(duplicateDir, "a.yaml"),
+		))
+	})
+
+	t.Run("non existent", func(t *testing.T) {
+		_, err := LoadPath(ctx, filepath.Join(dir, "non-existent"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestBlueprintMarshalYAML(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+
+	contents := "127.0.0.1 localhost\n"
+	var mode types.FileMode = 0644
+	blueprint := &Blueprint{
+		Entries: []*Entry{
+			{
+				TypeName: "File",
+				Resource: &resources.File{
+					Path:        "/etc/hosts",
+					RegularFile: &contents,
+					Mode:        &mode,
+				},
+			},
+			{
+				TypeName: "APTPackage",
+				Resource: &resources.APTPackage{
+					Package: "vim",
+				},
+			},
+		},
+	}
+
+	blueprintBytes, err := yaml.Marshal(blueprint)
+	require.NoError(t, err)
+	require.Equal(t, `- File:
+    path: /etc/hosts
+    regular_file: |
+        127.0.0.1 localhost
+    mode: 0644
+- APTPackage:
+    package: vim
+`, string(blueprintBytes))
+
+	loadedBlueprint, err := Load(ctx, "test.yaml", bytes.NewReader(blueprintBytes))
+	require.NoError(t, err)
+	require.Len(t, loadedBlueprint.Entries, 2)
+	for i, entry := range loadedBlueprint.Entries {
+		require.Equal(t, blueprint.Entries[i].TypeName, entry.TypeName)
+		require.Equal(t, blueprint.Entries[i].Resource, entry.Resource)
+	}
+}
+
+func TestBlueprintUnmarshalYAML(t *testing.T) {
+	t.Run("not validated", func(t *testing.T) {
+		var blueprint Blueprint
+		require.NoError(t, yaml.Unmarshal([]byte("- APTPackage:\n    package: vim\n    version: \"1.0\"\n"), &blueprint))
+		require.Equal(t, []*Entry{
+			{
+				TypeName: "APTPackage",
+				Resource: &resources.APTPackage{Package: "vim", Version: "1.0"},
+				Line:     1,
+			},
+		}, blueprint.Entries)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		var blueprint Blueprint
+		require.ErrorContains(t, yaml.Unmarshal([]byte("- Foo: {}\n"), &blueprint), `unknown resource type "Foo"`)
+	})
+}
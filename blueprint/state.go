@@ -0,0 +1,63 @@
+package blueprint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fornellas/resonance/host/types"
+	"github.com/fornellas/resonance/resources"
+)
+
+// Resolve all entries against given host, so that they can be compared with their loaded state
+// (eg: File user and group names are resolved to uid and gid).
+func (b *Blueprint) Resolve(ctx context.Context, host types.Host) error {
+	groupResourcesMap := map[resources.ResourceGroup][]resources.GroupResource{}
+	for _, entry := range b.Entries {
+		switch resource := entry.Resource.(type) {
+		case resources.SingleResource:
+			if err := resource.Resolve(ctx, host); err != nil {
+				return fmt.Errorf("%s: %s: %w", entry.Source(), entry.TypeName, err)
+			}
+		case resources.GroupResource:
+			groupResourcesMap[resource.Group()] = append(groupResourcesMap[resource.Group()], resource)
+		default:
+			panic(fmt.Sprintf("bug: %T is neither a SingleResource nor a GroupResource", entry.Resource))
+		}
+	}
+	for resourceGroup, groupResources := range groupResourcesMap {
+		if err := resourceGroup.Resolve(ctx, host, groupResources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load the current state of all entries from given host. The returned Blueprint has the same
+// entries, in the same order, with resources reflecting their current state at the host.
+func (b *Blueprint) Load(ctx context.Context, host types.Host) (*Blueprint, error) {
+	current := &Blueprint{
+		Entries: make([]*Entry, len(b.Entries)),
+	}
+	currentResources := make([]resources.Resource, len(b.Entries))
+	sources := make([]string, len(b.Entries))
+	for i, entry := range b.Entries {
+		resourceType := resources.GetResourceType(entry.TypeName)
+		if resourceType == nil {
+			panic(fmt.Sprintf("bug: unknown resource type %#v", entry.TypeName))
+		}
+		currentResources[i] = resourceType.New(entry.Resource.ID())
+		sources[i] = fmt.Sprintf("%s: %s", entry.Source(), entry.TypeName)
+		current.Entries[i] = &Entry{
+			TypeName: entry.TypeName,
+			Resource: currentResources[i],
+			Path:     entry.Path,
+			Line:     entry.Line,
+		}
+	}
+
+	if err := resources.LoadResources(ctx, host, currentResources, sources); err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -11,17 +12,199 @@ import (
 	"github.com/fornellas/slogxt/log"
 
 	"github.com/fornellas/resonance"
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/host/types"
+	planPkg "github.com/fornellas/resonance/plan"
+	"github.com/fornellas/resonance/sessionlog"
+	storePkg "github.com/fornellas/resonance/store"
 )
 
+// rollback restores all applied resources to their full state from before they were applied.
+func rollback(ctx context.Context, host types.Host, applied planPkg.Plan) error {
+	ctx, logger := log.MustWithGroup(ctx, "⏪ Rollback")
+	logger.Warn("Rolling back applied resources", "resources", len(applied))
+
+	plan, err := planPkg.NewExactPlan(ctx, host, planPkg.NewRollbackBlueprint(applied))
+	if err != nil {
+		return err
+	}
+
+	rolledBack, err := plan.Apply(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	if err := rolledBack.Refresh(ctx, host); err != nil {
+		return fmt.Errorf("failed to refresh: %w", err)
+	}
+
+	logger.Info("✅ Rollback successful")
+	return nil
+}
+
+var forceUnlock bool
+var defaultForceUnlock = false
+
+// applyBlueprint applies blueprint to host, restoring resources dropped since the last successful
+// apply, and rolling back on failure. The store is locked for the whole session, which is logged to
+// the store. ctx must have a logger with the group and attributes that the session log is set with.
+func applyBlueprint(
+	ctx context.Context,
+	cmd *cobra.Command,
+	host types.Host,
+	store storePkg.Store,
+	storeConfig string,
+	blueprint *blueprintPkg.Blueprint,
+	startTime time.Time,
+	group string,
+	groupAttrs ...slog.Attr,
+) (retErr error) {
+	logger := log.MustLogger(ctx)
+
+	if forceUnlock {
+		if err := store.ForceUnlock(ctx); err != nil {
+			return fmt.Errorf("failed to force unlock store: %w", err)
+		}
+	}
+	if err := store.Lock(ctx); err != nil {
+		var lockedErr *storePkg.LockedError
+		if errors.As(err, &lockedErr) {
+			err = fmt.Errorf("%w: if no other apply is running, retry with --force-unlock", err)
+		}
+		return fmt.Errorf("failed to lock store: %w", err)
+	}
+	defer func() {
+		// Unlocked even when interrupted, so that the next apply is not refused
+		if err := store.Unlock(context.WithoutCancel(ctx)); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to unlock store: %w", err))
+		}
+	}()
+
+	storelogWriterCloser, err := store.GetLogWriterCloser(ctx, "apply")
+	if err != nil {
+		return fmt.Errorf("failed to get store log writer: %w", err)
+	}
+	defer func() {
+		if err := storelogWriterCloser.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to close store log: %w", err))
+		}
+	}()
+
+	logHandler := logger.Handler()
+	storeHandler := sessionlog.NewHandler(storelogWriterCloser, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+	}).
+		WithAttrs([]slog.Attr{slog.String("version", resonance.Version)}).
+		WithGroup(group).WithAttrs(groupAttrs).
+		WithAttrs([]slog.Attr{slog.String("host", fmt.Sprintf("%s => %s", host.Type(), host.String()))}).
+		WithAttrs([]slog.Attr{slog.String("store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))})
+	logger = slog.New(log.NewMultiHandler(logHandler, storeHandler))
+	ctx = log.WithLogger(ctx, logger)
+	cmd.SetContext(ctx)
+
+	lastState, err := store.LoadState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	restoreBlueprint := storePkg.NewRestoreBlueprint(lastState, blueprint)
+	if len(restoreBlueprint.Entries) > 0 {
+		logger.Info(
+			"♻️ Restoring resources dropped from blueprint to their original state",
+			"resources", len(restoreBlueprint.Entries),
+		)
+	}
+
+	plan, err := planPkg.NewPlan(ctx, host, &blueprintPkg.Blueprint{
+		Entries: append(restoreBlueprint.Entries, blueprint.Entries...),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to plan: %w", err)
+	}
+
+	applied, err := plan.Apply(ctx, host)
+	if err != nil {
+		retErr = fmt.Errorf("failed to apply: %w", err)
+		if err := rollback(context.WithoutCancel(ctx), host, applied); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to rollback: %w", err))
+		}
+		return retErr
+	}
+
+	if err := applied.Refresh(ctx, host); err != nil {
+		retErr = fmt.Errorf("failed to refresh: %w", err)
+		if err := rollback(context.WithoutCancel(ctx), host, applied); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to rollback: %w", err))
+		}
+		return retErr
+	}
+
+	appliedBlueprint, err := blueprint.Load(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to load applied state: %w", err)
+	}
+
+	changed := []string{}
+	inSync := []string{}
+	for _, resourcePlan := range plan {
+		id := fmt.Sprintf("%s:%s", resourcePlan.Desired.TypeName, resourcePlan.Desired.Resource.ID())
+		if resourcePlan.HasChanges() {
+			changed = append(changed, id)
+		} else {
+			inSync = append(inSync, id)
+		}
+	}
+
+	metadata, err := storePkg.NewMetadata(startTime, resonance.Version, blueprint, changed, inSync)
+	if err != nil {
+		return err
+	}
+	if err := store.SaveState(
+		ctx, storePkg.NewState(
+			lastState, blueprint, plan.Current().Subtract(restoreBlueprint), appliedBlueprint,
+		),
+		metadata,
+	); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	summaryCtx, _ := log.MustWithGroup(ctx, "📋 Summary")
+	for _, resourcePlan := range plan {
+		_, resourceLogger := log.MustWithGroupAttrs(
+			summaryCtx, resourcePlan.Desired.TypeName, "source", resourcePlan.Desired.Source(),
+		)
+		if resourcePlan.HasChanges() {
+			resourceLogger.Info("🔧 Changed")
+		} else {
+			resourceLogger.Info("✅ In sync")
+		}
+	}
+
+	logger.Info("🎆 Apply successful", "changed", len(changed), "in_sync", len(inSync))
+
+	return nil
+}
+
 var ApplyCmd = &cobra.Command{
 	Use:   "apply [flags] [file|dir]",
 	Short: "Apply resources.",
-	Long:  "Load resources from file/dir and apply them.",
-	Args:  cobra.ExactArgs(1),
+	Long: "Load resources from file/dir and apply them. Resources dropped from the blueprint since " +
+		"the last successful apply are restored to their original state, from before they were first " +
+		"applied. If applying fails, all resources already touched are rolled back to their state " +
+		"from before the apply. Once all resources are applied, systemd units affected by " +
+		"the changes (eg: unit files, configuration of packages shipping services or upgraded " +
+		"packages) are reloaded or restarted, once. The store is locked for the whole session, so " +
+		"that concurrent applies to the same host are refused. Locks from sessions which are gone " +
+		"are released automatically only when held from the same host; locks held from other " +
+		"hosts are never released automatically, and require --force-unlock.",
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
+		startTime := time.Now()
 
-		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "✏️ Apply", "path", path)
+		pathAttr := slog.String("path", path)
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "✏️ Apply", pathAttr)
 
 		var retErr error
 		defer func() {
@@ -31,61 +214,65 @@ var ApplyCmd = &cobra.Command{
 			}
 		}()
 
-		host, ctx, err := GetHost(ctx)
+		blueprint, err := blueprintPkg.LoadPath(ctx, path)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load blueprint: %w", err))
+			return
+		}
+
+		if dockerImageCommit != "" && dockerImage == "" {
+			retErr = errors.Join(retErr, errors.New("--host-docker-image-commit requires --host-docker-image"))
+			return
+		}
+
+		host, dockerImageHost, ctx, err := getHost(ctx)
 		if err != nil {
 			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
 			return
 		}
 		defer func() {
-			if err := host.Close(ctx); err != nil {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
 				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
 			}
 		}()
 		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
 
-		store, storeConfig, err := GetStore(host)
+		store, storeConfig, err := GetStore(ctx, host)
 		if err != nil {
 			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
 			return
 		}
-		ctx, logger = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
 
-		storelogWriterCloser, err := store.GetLogWriterCloser(ctx, "apply")
-		if err != nil {
-			retErr = errors.Join(retErr, fmt.Errorf("failed to get store log writer: %w", err))
+		if err := applyBlueprint(
+			ctx, cmd, host, store, storeConfig, blueprint, startTime, "✏️ Apply", pathAttr,
+		); err != nil {
+			retErr = errors.Join(retErr, err)
 			return
 		}
-		defer func() {
-			if err := storelogWriterCloser.Close(); err != nil {
-				retErr = errors.Join(retErr, fmt.Errorf("failed to close store log: %w", err))
-			}
-		}()
 
-		logHandler := logger.Handler()
-		storeHandler := log.NewTerminalLineHandler(storelogWriterCloser, &log.TerminalHandlerOptions{
-			HandlerOptions: slog.HandlerOptions{
-				AddSource: true,
-				Level:     slog.LevelDebug,
-			},
-			TimeLayout: time.RFC3339,
-			NoColor:    true,
-		}).
-			WithAttrs([]slog.Attr{slog.String("version", resonance.Version)}).
-			WithGroup("✏️ Apply").WithAttrs([]slog.Attr{slog.String("path", path)}).
-			WithAttrs([]slog.Attr{slog.String("host", fmt.Sprintf("%s => %s", host.Type(), host.String()))}).
-			WithAttrs([]slog.Attr{slog.String("store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))})
-		logger = slog.New(log.NewMultiHandler(logHandler, storeHandler))
-		ctx = log.WithLogger(ctx, logger)
-		cmd.SetContext(ctx)
-
-		panic("TODO")
+		// Only successful applies are committed
+		if dockerImageHost != nil {
+			dockerImageHost.CommitTag = dockerImageCommit
+		}
 	},
 }
 
 func init() {
 	AddHostFlags(ApplyCmd)
+	AddDockerImageCommitFlag(ApplyCmd)
 
 	AddStoreFlags(ApplyCmd)
 
+	ApplyCmd.Flags().BoolVarP(
+		&forceUnlock, "force-unlock", "", defaultForceUnlock,
+		"Remove any existing store lock before applying, even if held by another session. Required "+
+			"for locks held from other hosts, as they are never released automatically",
+	)
+
 	RootCmd.AddCommand(ApplyCmd)
+
+	resetFlagsFns = append(resetFlagsFns, func() {
+		forceUnlock = defaultForceUnlock
+	})
 }
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	hostPkg "github.com/fornellas/resonance/host"
+	storePkg "github.com/fornellas/resonance/store"
+)
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	inSyncPath := filepath.Join(dir, "in-sync")
+	require.NoError(t, os.WriteFile(inSyncPath, []byte("foo"), 0600))
+	changedPath := filepath.Join(dir, "changed")
+	require.NoError(t, os.WriteFile(changedPath, []byte("foo"), 0600))
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n"+
+			"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		inSyncPath, os.Getuid(), os.Getgid(),
+		changedPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "local", "--store-local-path", storePath, blueprintPath,
+		},
+		ExpectStderrContains: []string{"Changed", "In sync", "Apply successful"},
+	}
+	cmd.Run(t)
+
+	changedBytes, err := os.ReadFile(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, "bar", string(changedBytes))
+
+	stateBytes, err := os.ReadFile(filepath.Join(getLocalStoreHostPath(t, storePath), "state", "v1", "state.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(stateBytes), "regular_file: bar")
+	require.Contains(t, string(stateBytes), "regular_file: foo")
+
+	cmd = TestCmd{
+		Args:                 []string{"plan", "--host-local", blueprintPath},
+		ExpectStderrContains: []string{"All resources in sync"},
+	}
+	cmd.Run(t)
+}
+
+func TestApplyChroot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chroot requires root")
+	}
+
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: 0\n    gid: 0\n", filePath,
+	)), 0600))
+
+	t.Run("apply", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: []string{
+				"apply", "--host-chroot", "/", "--store", "local", "--store-local-path", storePath, blueprintPath,
+			},
+			ExpectStderrContains: []string{"chroot => /", "Apply successful"},
+		}
+		cmd.Run(t)
+
+		fileBytes, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.Equal(t, "foo", string(fileBytes))
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"plan", "--host-chroot", blueprintPath, blueprintPath},
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"failed to get host", "not a directory"},
+		}
+		cmd.Run(t)
+	})
+}
+
+func TestApplyDockerImageCommitWithoutImage(t *testing.T) {
+	blueprintPath := filepath.Join(t.TempDir(), "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte("[]\n"), 0600))
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--host-docker-image-commit", "example:tag", blueprintPath,
+		},
+		ExpectedCode:         1,
+		ExpectStderrContains: []string{"--host-docker-image-commit requires --host-docker-image"},
+	}
+	cmd.Run(t)
+}
+
+// interruptingStore cancels the context once locked, as interrupting an apply does, and refuses to
+// unlock with a cancelled context.
+type interruptingStore struct {
+	storePkg.Store
+	cancel context.CancelFunc
+}
+
+func (s *interruptingStore) Lock(ctx context.Context) error {
+	if err := s.Store.Lock(ctx); err != nil {
+		return err
+	}
+	s.cancel()
+	return nil
+}
+
+func (s *interruptingStore) Unlock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Unlock(ctx)
+}
+
+func TestApplyInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filepath.Join(dir, "file"), os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	ctx, cancel := context.WithCancel(log.WithTestLogger(t.Context()))
+	defer cancel()
+
+	blueprint, err := blueprintPkg.LoadPath(ctx, blueprintPath)
+	require.NoError(t, err)
+
+	host := hostPkg.Local{}
+	store := &interruptingStore{Store: storePkg.NewHostStore(host, storePath), cancel: cancel}
+
+	err = applyBlueprint(ctx, &cobra.Command{}, host, store, storePath, blueprint, time.Now(), "✏️ Apply")
+	if err != nil {
+		require.NotContains(t, err.Error(), "failed to unlock store")
+	}
+	require.Error(t, ctx.Err())
+
+	_, err = os.Lstat(filepath.Join(storePath, "lock"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestApplyRollback(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	changedPath := filepath.Join(dir, "changed")
+	require.NoError(t, os.WriteFile(changedPath, []byte("foo"), 0600))
+	failPath := filepath.Join(dir, "non-existent", "fail")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n"+
+			"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		changedPath, os.Getuid(), os.Getgid(),
+		failPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "local", "--store-local-path", storePath, blueprintPath,
+		},
+		ExpectedCode:         1,
+		ExpectStderrContains: []string{"failed to apply", "Rollback successful"},
+	}
+	cmd.Run(t)
+
+	changedBytes, err := os.ReadFile(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(changedBytes))
+
+	logPaths, err := filepath.Glob(filepath.Join(getLocalStoreHostPath(t, storePath), "logs", "*.apply.gz"))
+	require.NoError(t, err)
+	require.Len(t, logPaths, 1)
+	logFile, err := os.Open(logPaths[0])
+	require.NoError(t, err)
+	defer logFile.Close()
+	gzipReader, err := gzip.NewReader(logFile)
+	require.NoError(t, err)
+	logBytes, err := io.ReadAll(gzipReader)
+	require.NoError(t, err)
+	require.Contains(t, string(logBytes), "Rollback successful")
+}
+
+func TestApplyRestoreDropped(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	createdPath := filepath.Join(dir, "created")
+	changedPath := filepath.Join(dir, "changed")
+	require.NoError(t, os.WriteFile(changedPath, []byte("original"), 0600))
+	keptPath := filepath.Join(dir, "kept")
+
+	writeBlueprint := func(paths ...string) string {
+		blueprintPath := filepath.Join(t.TempDir(), "blueprint.yaml")
+		contents := ""
+		for _, path := range paths {
+			contents += fmt.Sprintf(
+				"- File:\n    path: %s\n    regular_file: managed\n    uid: %d\n    gid: %d\n",
+				path, os.Getuid(), os.Getgid(),
+			)
+		}
+		require.NoError(t, os.WriteFile(blueprintPath, []byte(contents), 0600))
+		return blueprintPath
+	}
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), writeBlueprint(createdPath, changedPath, keptPath)),
+	}
+	cmd.Run(t)
+
+	cmd = TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), writeBlueprint(changedPath, keptPath)),
+	}
+	cmd.Run(t)
+
+	_, err := os.Stat(createdPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	cmd = TestCmd{
+		Args:                 append(append([]string{"apply"}, storeArgs...), writeBlueprint(keptPath)),
+		ExpectStderrContains: []string{"Restoring resources dropped from blueprint"},
+	}
+	cmd.Run(t)
+
+	changedBytes, err := os.ReadFile(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, "original", string(changedBytes))
+
+	keptBytes, err := os.ReadFile(keptPath)
+	require.NoError(t, err)
+	require.Equal(t, "managed", string(keptBytes))
+}
+
+func TestApplyLock(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	changedPath := filepath.Join(dir, "changed")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		changedPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	hostIdentity, err := storePkg.GetHostIdentity(ctx, hostPkg.Local{})
+	require.NoError(t, err)
+	localStore, err := storePkg.NewLocalStore(ctx, hostPkg.Local{}, storePath, hostIdentity)
+	require.NoError(t, err)
+	require.NoError(t, localStore.Lock(ctx))
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "local", "--store-local-path", storePath, blueprintPath,
+		},
+		ExpectedCode:         1,
+		ExpectStderrContains: []string{"store is locked by", "--force-unlock"},
+	}
+	cmd.Run(t)
+
+	_, err = os.Stat(changedPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	cmd = TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "local", "--store-local-path", storePath,
+			"--force-unlock", blueprintPath,
+		},
+		ExpectStderrContains: []string{"Forcing unlock", "Apply successful"},
+	}
+	cmd.Run(t)
+
+	changedBytes, err := os.ReadFile(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, "bar", string(changedBytes))
+
+	_, err = os.Stat(filepath.Join(getLocalStoreHostPath(t, storePath), "lock"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestApplyGitStore(t *testing.T) {
+	dir := t.TempDir()
+	repoPath := filepath.Join(dir, "repo")
+
+	changedPath := filepath.Join(dir, "changed")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		changedPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "git", "--store-git-path", repoPath, blueprintPath,
+		},
+		ExpectStderrContains: []string{"Apply successful"},
+	}
+	cmd.Run(t)
+
+	output, err := exec.Command("git", "-C", repoPath, "log", "--format=%s").CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.Equal(t, "localhost: apply: 1 changed, 0 in sync\n", string(output))
+}
+
+func TestApplyHTTPStore(t *testing.T) {
+	dir := t.TempDir()
+	serverPath := filepath.Join(dir, "server")
+	server := httptest.NewServer(storePkg.NewHTTPStoreHandler(serverPath))
+	t.Cleanup(server.Close)
+
+	changedPath := filepath.Join(dir, "changed")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		changedPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	storeArgs := []string{"--host-local", "--store", "http", "--store-http-url", server.URL}
+
+	cmd := TestCmd{
+		Args:                 append(append([]string{"apply"}, storeArgs...), blueprintPath),
+		ExpectStderrContains: []string{"Apply successful"},
+	}
+	cmd.Run(t)
+
+	stateBytes, err := os.ReadFile(filepath.Join(serverPath, "localhost", "state.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(stateBytes), "regular_file: bar")
+
+	_, err = os.Stat(filepath.Join(serverPath, "localhost", "lock.yaml"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	cmd = TestCmd{
+		Args:                 append([]string{"history"}, storeArgs...),
+		ExpectStdoutContains: []string{"\n1 "},
+	}
+	cmd.Run(t)
+
+	cmd = TestCmd{
+		Args:                 append([]string{"logs", "list"}, storeArgs...),
+		ExpectStdoutContains: []string{".apply"},
+	}
+	cmd.Run(t)
+}
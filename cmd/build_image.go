@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance"
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	hostPkg "github.com/fornellas/resonance/host"
+)
+
+// Labels set at images built by build-image.
+const (
+	imageLabelBlueprintChecksum = "io.github.fornellas.resonance.blueprint-checksum"
+	imageLabelVersion           = "io.github.fornellas.resonance.version"
+	imageLabelBaseName          = "org.opencontainers.image.base.name"
+)
+
+var buildImageFrom string
+var defaultBuildImageFrom = ""
+
+var buildImageTag string
+var defaultBuildImageTag = ""
+
+var BuildImageCmd = &cobra.Command{
+	Use:   "build-image [flags] [file|dir]",
+	Short: "Build a container image by applying resources.",
+	Long: "Start a Docker container from the base image, apply resources from file/dir to it, as " +
+		"apply does, and commit it to a new image, labeled with the blueprint checksum and " +
+		"resonance version. The base image entrypoint and command are kept. The container is " +
+		"removed when done, and the image is only committed if applying succeeds. State is saved " +
+		"to the store, which by default is inside the image, so that containers created from it can " +
+		"be applied to later.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		startTime := time.Now()
+
+		pathAttr := slog.String("path", path)
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "🏗️ Build Image", pathAttr)
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		blueprint, err := blueprintPkg.LoadPath(ctx, path)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load blueprint: %w", err))
+			return
+		}
+		checksum, err := blueprint.Checksum()
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get blueprint checksum: %w", err))
+			return
+		}
+
+		dockerImageHost, err := hostPkg.NewDockerImage(ctx, buildImageFrom, "")
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to start container: %w", err))
+			return
+		}
+		host, ctx, err := wrapBaseHost(ctx, dockerImageHost)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		if err := applyBlueprint(
+			ctx, cmd, host, store, storeConfig, blueprint, startTime, "🏗️ Build Image", pathAttr,
+		); err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+
+		dockerImageHost.CommitTag = buildImageTag
+		dockerImageHost.CommitLabels[imageLabelBlueprintChecksum] = checksum
+		dockerImageHost.CommitLabels[imageLabelVersion] = resonance.Version
+		dockerImageHost.CommitLabels[imageLabelBaseName] = dockerImageHost.Image
+	},
+}
+
+func init() {
+	BuildImageCmd.Flags().StringVar(
+		&buildImageFrom, "from", defaultBuildImageFrom,
+		"Base image to build from \n"+
+			"Use given format '[<name|uid>[:<group|gid>]@]<image>'",
+	)
+	BuildImageCmd.MarkFlagRequired("from")
+
+	BuildImageCmd.Flags().StringVar(
+		&buildImageTag, "tag", defaultBuildImageTag,
+		"Tag of the built image",
+	)
+	BuildImageCmd.MarkFlagRequired("tag")
+
+	AddStoreFlags(BuildImageCmd)
+
+	RootCmd.AddCommand(BuildImageCmd)
+
+	resetFlagsFns = append(resetFlagsFns, func() {
+		buildImageFrom = defaultBuildImageFrom
+		buildImageTag = defaultBuildImageTag
+	})
+}
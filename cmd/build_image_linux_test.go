@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance"
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	hostPkg "github.com/fornellas/resonance/host"
+)
+
+// fakeDocker is a docker replacement, which logs all its invocations to $FAKE_DOCKER_LOG, and runs
+// docker exec commands locally at the working directory, ignoring the user and container.
+var fakeDocker = `#!/bin/sh
+set -e
+echo "$*" >> "$FAKE_DOCKER_LOG"
+case "$1" in
+	run)
+		;;
+	exec)
+		shift
+		while [ "${1#--}" != "$1" ] ; do
+			case "$1" in
+				--workdir)
+					shift
+					cd "$1"
+					;;
+				--user)
+					shift
+					;;
+			esac
+			shift
+		done
+		shift
+		exec "$@"
+		;;
+	image)
+		echo '{"Entrypoint":null,"Cmd":["bash"]}'
+		;;
+	commit)
+		echo sha256:fedcba9876543210
+		;;
+	rm)
+		;;
+	*)
+		exit 1
+		;;
+esac
+`
+
+func TestBuildImage(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	binDir := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(binDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "docker"), []byte(fakeDocker), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	dockerLogPath := filepath.Join(dir, "docker.log")
+	t.Setenv("FAKE_DOCKER_LOG", dockerLogPath)
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	storeArgs := []string{"--store", "local", "--store-local-path", storePath}
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(dockerLogPath, []byte{}, 0600))
+		cmd := TestCmd{
+			Args: append(append(
+				[]string{"build-image", "--from", "debian:trixie", "--tag", "example:tag"}, storeArgs...,
+			), blueprintPath),
+			ExpectStderrContains: []string{"Apply successful", "Committed container", "sha256:fedcba9876543210"},
+		}
+		cmd.Run(t)
+
+		fileBytes, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.Equal(t, "foo", string(fileBytes))
+
+		blueprint, err := blueprintPkg.LoadPath(log.WithTestLogger(t.Context()), blueprintPath)
+		require.NoError(t, err)
+		checksum, err := blueprint.Checksum()
+		require.NoError(t, err)
+
+		dockerLogBytes, err := os.ReadFile(dockerLogPath)
+		require.NoError(t, err)
+		dockerLog := string(dockerLogBytes)
+		require.Contains(t, dockerLog, "--label "+hostPkg.DockerImageContainerLabel+"=true ")
+		require.Contains(t, dockerLog, "--entrypoint sleep debian:trixie infinity")
+		require.Contains(t, dockerLog, fmt.Sprintf("--change LABEL %q=%q", imageLabelBlueprintChecksum, checksum))
+		require.Contains(t, dockerLog, fmt.Sprintf("--change LABEL %q=%q", imageLabelVersion, resonance.Version))
+		require.Contains(t, dockerLog, fmt.Sprintf("--change LABEL %q=%q", imageLabelBaseName, "debian:trixie"))
+		require.Contains(t, dockerLog, " example:tag\n")
+		require.Contains(t, dockerLog, "rm --force ")
+	})
+
+	t.Run("apply failure", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(dockerLogPath, []byte{}, 0600))
+
+		failBlueprintPath := filepath.Join(dir, "fail.yaml")
+		require.NoError(t, os.WriteFile(failBlueprintPath, []byte(fmt.Sprintf(
+			"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+			filepath.Join(dir, "non-existent", "file"), os.Getuid(), os.Getgid(),
+		)), 0600))
+
+		cmd := TestCmd{
+			Args: append(append(
+				[]string{"build-image", "--from", "debian:trixie", "--tag", "example:tag"}, storeArgs...,
+			), failBlueprintPath),
+			ExpectedCode: 1,
+		}
+		cmd.Run(t)
+
+		dockerLogBytes, err := os.ReadFile(dockerLogPath)
+		require.NoError(t, err)
+		dockerLog := string(dockerLogBytes)
+		require.NotContains(t, dockerLog, "commit ")
+		require.Contains(t, dockerLog, "rm --force ")
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/diff"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff [flags] id1 id2",
+	Short: "Show differences between two previous applies.",
+	Long: "Compare the blueprints applied by two previous applies from the store history, " +
+		"reporting all resources added, removed or changed from id1 to id2. Use the history " +
+		"command to list available ids.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "📜 Diff", "id1", args[0], "id2", args[1])
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		historyEntry1, err := loadHistoryEntry(ctx, store, args[0])
+		if err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+		blueprint1 := historyEntry1.State.Blueprint
+
+		historyEntry2, err := loadHistoryEntry(ctx, store, args[1])
+		if err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+		blueprint2 := historyEntry2.State.Blueprint
+
+		differences := 0
+
+		for _, entry := range blueprint1.Subtract(blueprint2).Entries {
+			differences++
+			_, logger := log.MustWithGroupAttrs(ctx, entry.TypeName, "id", entry.Resource.ID())
+			logger.Info("➖ Removed")
+		}
+
+		for _, entry2 := range blueprint2.Entries {
+			_, logger := log.MustWithGroupAttrs(ctx, entry2.TypeName, "id", entry2.Resource.ID())
+			entry1 := blueprint1.Get(entry2.TypeName, entry2.Resource.ID())
+			if entry1 == nil {
+				differences++
+				chunks := diff.DiffAsYaml(nil, entry2.Resource)
+				logger.Info("➕ Added", "diff", log.NewTerminalValue(chunks.TerminalString()))
+				continue
+			}
+			chunks := diff.DiffAsYaml(entry1.Resource, entry2.Resource)
+			if chunks.HasChanges() {
+				differences++
+				logger.Info("🔧 Changed", "diff", log.NewTerminalValue(chunks.TerminalString()))
+			}
+		}
+
+		if differences > 0 {
+			logger.Info("🔀 Applies differ", "resources", differences)
+		} else {
+			logger.Info("🎆 Applies are equivalent")
+		}
+	},
+}
+
+func init() {
+	AddHostFlags(DiffCmd)
+
+	AddStoreFlags(DiffCmd)
+
+	RootCmd.AddCommand(DiffCmd)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/diff"
+	planPkg "github.com/fornellas/resonance/plan"
+)
+
+// DriftExitCode is the exit code used by drift when resources drifted from the last applied state.
+var DriftExitCode = 2
+
+var DriftCmd = &cobra.Command{
+	Use:   "drift [flags]",
+	Short: "Detect changes made to host since the last apply.",
+	Long: "Load the state of all resources right after the last apply from the store and compare " +
+		"it exactly with their current state at the host, reporting any resources changed since, " +
+		"including fields not declared at the blueprint.\n\n" +
+		fmt.Sprintf(
+			"Exits with code %d when resources drifted, 1 on errors, or 0 when all resources are in sync.",
+			DriftExitCode,
+		),
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroup(cmd.Context(), "🧭 Drift")
+
+		var retErr error
+		var hasDrift bool
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+			if hasDrift {
+				Exit(DriftExitCode)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		state, err := store.LoadState(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load state: %w", err))
+			return
+		}
+		if state == nil {
+			retErr = errors.Join(retErr, errors.New("no state found at store: a blueprint must be applied first"))
+			return
+		}
+
+		var plan planPkg.Plan
+		if state.Applied != nil {
+			plan, err = planPkg.NewExactPlan(ctx, host, state.Applied)
+		} else {
+			logger.Warn(
+				"State saved by an older version has no applied state, comparing with the last " +
+					"applied blueprint instead: changes to fields not declared at it are not detected",
+			)
+			plan, err = planPkg.NewPlan(ctx, host, state.Blueprint)
+		}
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load host state: %w", err))
+			return
+		}
+
+		for _, resourcePlan := range plan {
+			_, logger := log.MustWithGroupAttrs(
+				ctx, resourcePlan.Desired.TypeName, "id", resourcePlan.Desired.Resource.ID(),
+			)
+			if resourcePlan.HasChanges() {
+				chunks := diff.DiffAsYaml(resourcePlan.Desired.Resource, resourcePlan.Current)
+				logger.Warn("🔀 Drifted", "diff", log.NewTerminalValue(chunks.TerminalString()))
+			} else {
+				logger.Info("✅ In sync")
+			}
+		}
+
+		if plan.HasChanges() {
+			hasDrift = true
+			logger.Warn("🔀 Host drifted from last applied state")
+		} else {
+			logger.Info("🎆 All resources in sync with last applied state")
+		}
+	},
+}
+
+func init() {
+	AddHostFlags(DriftCmd)
+
+	AddStoreFlags(DriftCmd)
+
+	RootCmd.AddCommand(DriftCmd)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrift(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+
+	t.Run("no state", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"no state found at store"},
+		}
+		cmd.Run(t)
+	})
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	t.Run("in sync", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectStderrContains: []string{"All resources in sync with last applied state"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("undeclared field drifted", func(t *testing.T) {
+		fileInfo, err := os.Stat(filePath)
+		require.NoError(t, err)
+		require.NoError(t, os.Chmod(filePath, 0640))
+		defer func() { require.NoError(t, os.Chmod(filePath, fileInfo.Mode())) }()
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectedCode:         DriftExitCode,
+			ExpectStderrContains: []string{"Drifted", "mode: 0640"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("drifted", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filePath, []byte("bar"), 0600))
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectedCode:         DriftExitCode,
+			ExpectStderrContains: []string{"Drifted", "regular_file: bar"},
+		}
+		cmd.Run(t)
+	})
+}
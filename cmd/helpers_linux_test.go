@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -72,28 +73,33 @@ func (c *TestCmd) Run(t *testing.T) {
 	osExitErr := errors.New("os.Exit")
 	originalExit := Exit
 	t.Cleanup(func() { Exit = originalExit })
+	exitCode := 0
 	Exit = func(code int) {
-		if c.ExpectedCode != code {
-			t.Fatalf("%v exited %d, expected %d", c, code, c.ExpectedCode)
-		}
+		exitCode = code
 		panic(osExitErr)
 	}
-	defer func() {
-		if r := recover(); r != nil && r != osExitErr {
-			panic(r)
-		}
-	}()
 
+	// Flags from previous runs at the same test must not leak into this one
+	ResetFlags()
 	t.Cleanup(func() { ResetFlags() })
 
 	RootCmd.SetArgs(c.Args)
 
 	stdout, stderr := captureOutput(t, func() {
+		defer func() {
+			if r := recover(); r != nil && r != osExitErr {
+				panic(r)
+			}
+		}()
 		if err := RootCmd.Execute(); err != nil {
 			t.Fatal(err)
 		}
 	})
 
+	if exitCode != c.ExpectedCode {
+		t.Fatalf("%v exited %d, expected %d", c, exitCode, c.ExpectedCode)
+	}
+
 	for _, str := range c.ExpectStdoutContains {
 		require.Contains(t, stdout, str, "stdout does not contain expected content")
 	}
@@ -102,3 +108,12 @@ func (c *TestCmd) Run(t *testing.T) {
 		require.Contains(t, stderr, str, "stderr does not contain expected content")
 	}
 }
+
+// getLocalStoreHostPath returns the path of the directory for localhost at the local store at
+// storePath.
+func getLocalStoreHostPath(t *testing.T, storePath string) string {
+	hostPaths, err := filepath.Glob(filepath.Join(storePath, "localhost*"))
+	require.NoError(t, err)
+	require.Len(t, hostPaths, 1)
+	return hostPaths[0]
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	storePkg "github.com/fornellas/resonance/store"
+)
+
+// loadHistoryEntry loads the history entry from store with given id, as passed as an argument.
+func loadHistoryEntry(ctx context.Context, store storePkg.Store, idStr string) (*storePkg.HistoryEntry, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history id %#v: %w", idStr, err)
+	}
+	historyEntry, err := store.LoadHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history entry %d: %w", id, err)
+	}
+	if historyEntry == nil {
+		return nil, fmt.Errorf("history entry %d not found at store", id)
+	}
+	return historyEntry, nil
+}
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history [flags]",
+	Short: "List previous applies.",
+	Long: "List all previous applies kept at the store history, oldest first, with their time, " +
+		"resonance version, blueprint checksum and number of changed resources.",
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroup(cmd.Context(), "📜 History")
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		metadatas, err := store.ListHistory(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to list history: %w", err))
+			return
+		}
+
+		tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "ID\tTIME\tVERSION\tBLUEPRINT CHECKSUM\tCHANGED\tIN SYNC")
+		for _, metadata := range metadatas {
+			fmt.Fprintf(
+				tabWriter, "%d\t%s\t%s\t%s\t%d\t%d\n",
+				metadata.ID, metadata.Time.Local().Format(time.RFC3339), metadata.Version,
+				metadata.BlueprintChecksum,
+				len(metadata.Changed), metadata.InSync,
+			)
+		}
+		if err := tabWriter.Flush(); err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+	},
+}
+
+func init() {
+	AddHostFlags(HistoryCmd)
+
+	AddStoreFlags(HistoryCmd)
+
+	RootCmd.AddCommand(HistoryCmd)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+	droppedPath := filepath.Join(dir, "dropped")
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n"+
+			"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+		droppedPath, os.Getuid(), os.Getgid(),
+	)), 0600))
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: bar\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+	cmd = TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	t.Run("history", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"history"}, storeArgs...),
+			ExpectStdoutContains: []string{"ID", "BLUEPRINT CHECKSUM", "\n1 ", "\n2 ", "sha256:"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("show", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: append(append([]string{"show"}, storeArgs...), "1"),
+			ExpectStdoutContains: []string{
+				"id: 1", "blueprint_checksum: sha256:", "File:" + droppedPath, "regular_file: foo",
+			},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("show not found", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"show"}, storeArgs...), "3"),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"history entry 3 not found at store"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"diff"}, storeArgs...), "1", "2"),
+			ExpectStderrContains: []string{"Removed", droppedPath, "Changed", "Applies differ"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("diff equivalent", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"diff"}, storeArgs...), "2", "2"),
+			ExpectStderrContains: []string{"Applies are equivalent"},
+		}
+		cmd.Run(t)
+	})
+}
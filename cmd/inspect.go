@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/resources"
 )
 
 type ResourceTypeValue struct {
@@ -18,10 +24,15 @@ func (r *ResourceTypeValue) String() string {
 }
 
 func (r *ResourceTypeValue) Set(name string) error {
-	panic("TODO")
+	if resources.GetResourceType(name) == nil {
+		return fmt.Errorf("invalid resource type '%s', valid options are %s", name, r.Type())
+	}
+	r.name = name
+	return nil
 }
+
 func (r *ResourceTypeValue) Type() string {
-	panic("TODO")
+	return fmt.Sprintf("[%s]", strings.Join(resources.ResourceTypeNames(), "|"))
 }
 
 func (r *ResourceTypeValue) Reset() {
@@ -36,7 +47,7 @@ var defaultResourceNames = []string{}
 var InspectCmd = &cobra.Command{
 	Use:   "inspect [flags]",
 	Short: "Inspect resources from host",
-	Long:  "Load resources from host and print them to stdout.",
+	Long:  "Load resources from host and print them to stdout as a blueprint.",
 	Args:  cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "🔎 Inspect")
@@ -55,13 +66,33 @@ var InspectCmd = &cobra.Command{
 			return
 		}
 		defer func() {
-			if err := host.Close(ctx); err != nil {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
 				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
 			}
 		}()
 		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
 
-		panic("TODO")
+		resourceType := resources.GetResourceType(resourceTypeValue.String())
+		loadedResources, err := resourceType.Load(ctx, host, resourceIds)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load: %w", err))
+			return
+		}
+
+		blueprint := &blueprintPkg.Blueprint{}
+		for _, resource := range loadedResources {
+			blueprint.Entries = append(blueprint.Entries, &blueprintPkg.Entry{
+				TypeName: resourceType.Name,
+				Resource: resource,
+			})
+		}
+
+		blueprintBytes, err := yaml.Marshal(blueprint)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to marshal: %w", err))
+			return
+		}
+		fmt.Print(string(blueprintBytes))
 	},
 }
 
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	fooPath := filepath.Join(dir, "foo")
+	require.NoError(t, os.WriteFile(fooPath, []byte("foo"), 0640))
+	barPath := filepath.Join(dir, "bar")
+
+	t.Run("File", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: []string{
+				"inspect", "--host-local", "--resource-type", "File", "--resource-ids", fooPath + "," + barPath,
+			},
+			ExpectStdoutContains: []string{fmt.Sprintf(
+				"- File:\n    path: %s\n    regular_file: foo\n    mode: 0640\n    uid: %d\n    gid: %d\n"+
+					"- File:\n    path: %s\n    absent: true\n",
+				fooPath, os.Getuid(), os.Getgid(), barPath,
+			)},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("invalid resource type", func(t *testing.T) {
+		t.Cleanup(func() { ResetFlags() })
+		RootCmd.SetArgs([]string{
+			"inspect", "--host-local", "--resource-type", "Foo", "--resource-ids", fooPath,
+		})
+		require.ErrorContains(
+			t, RootCmd.Execute(), "invalid resource type 'Foo', valid options are [APTPackage|DpkgArch|File]",
+		)
+	})
+}
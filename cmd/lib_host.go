@@ -40,6 +40,15 @@ var defaultSshTcpConnectTimeout = time.Second * 30
 var docker string
 var defaultDocker = ""
 
+var dockerImage string
+var defaultDockerImage = ""
+
+var dockerImageCommit string
+var defaultDockerImageCommit = ""
+
+var podman string
+var defaultPodman = ""
+
 var sudo bool
 var defaultSudo = false
 
@@ -87,6 +96,22 @@ func AddHostFlags(cmd *cobra.Command) {
 			"Use given format '[<name|uid>[:<group|gid>]@]<image>'",
 	)
 	hostFlagNames = append(hostFlagNames, "host-docker")
+	cmd.Flags().StringVar(
+		&dockerImage, "host-docker-image", defaultDockerImage,
+		"Applies configuration to an ephemeral Docker container started from given image, which is "+
+			"removed when done, even if interrupted; containers left behind, eg: when killed, are "+
+			"labeled "+hostPkg.DockerImageContainerLabel+"=true \n"+
+			"Use given format '[<name|uid>[:<group|gid>]@]<image>'",
+	)
+	hostFlagNames = append(hostFlagNames, "host-docker-image")
+
+	// Podman
+	cmd.Flags().StringVar(
+		&podman, "host-podman", defaultPodman,
+		"Applies configuration to given Podman container name, rootful or rootless \n"+
+			"Use given format '[<name|uid>[:<group|gid>]@]<container>'",
+	)
+	hostFlagNames = append(hostFlagNames, "host-podman")
 
 	// Common
 	cmd.Flags().BoolVarP(
@@ -101,9 +126,28 @@ func AddHostFlags(cmd *cobra.Command) {
 	cmd.MarkFlagsOneRequired(hostFlagNames...)
 }
 
-func GetHost(ctx context.Context) (_ types.Host, _ context.Context, retErr error) {
+// AddDockerImageCommitFlag adds --host-docker-image-commit to cmd, which must commit the host from
+// getHost with it, after successfully applying.
+func AddDockerImageCommitFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&dockerImageCommit, "host-docker-image-commit", defaultDockerImageCommit,
+		"When used with --host-docker-image, commit the container to an image with given tag after a successful apply, before removing it",
+	)
+}
+
+func GetHost(ctx context.Context) (types.Host, context.Context, error) {
+	host, _, ctx, err := getHost(ctx)
+	return host, ctx, err
+}
+
+// getHost is as GetHost, but also returns the host started for --host-docker-image, or nil.
+func getHost(ctx context.Context) (types.Host, *hostPkg.DockerImage, context.Context, error) {
+	var dockerImageHost *hostPkg.DockerImage
 
-	baseHost := getBaseHostArch(ctx)
+	baseHost, err := getBaseHostArch(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	if baseHost == nil {
 		if ssh != "" {
 			var err error
@@ -116,33 +160,62 @@ func GetHost(ctx context.Context) (_ types.Host, _ context.Context, retErr error
 				Timeout:           sshTcpConnectTimeout,
 			})
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 		} else if docker != "" {
 			var err error
 			baseHost, err = hostPkg.NewDocker(ctx, docker)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
+			}
+		} else if dockerImage != "" {
+			var err error
+			dockerImageHost, err = hostPkg.NewDockerImage(ctx, dockerImage, "")
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			baseHost = dockerImageHost
+		} else if podman != "" {
+			var err error
+			baseHost, err = hostPkg.NewPodman(ctx, podman)
+			if err != nil {
+				return nil, nil, nil, err
 			}
 		} else {
 			panic("bug: no host set")
 		}
 	}
 
+	host, ctx, err := wrapBaseHost(ctx, baseHost)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return host, dockerImageHost, ctx, nil
+}
+
+// wrapBaseHost wraps baseHost with the agent, returning a Host ready to be used, and a context with
+// the host concurrency limit set. baseHost is closed when the returned host is closed, or on
+// error.
+func wrapBaseHost(ctx context.Context, baseHost types.BaseHost) (_ types.Host, _ context.Context, retErr error) {
 	if sudo {
-		var err error
-		baseHost, err = hostPkg.NewSudoWrapper(ctx, baseHost)
+		sudoWrapper, err := hostPkg.NewSudoWrapper(ctx, baseHost)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, errors.Join(err, baseHost.Close(context.WithoutCancel(ctx)))
 		}
+		baseHost = sudoWrapper
 	}
 
 	var host types.Host
 
 	host, err := hostPkg.NewAgentClientWrapper(ctx, baseHost)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, errors.Join(err, baseHost.Close(context.WithoutCancel(ctx)))
 	}
+	defer func() {
+		if retErr != nil {
+			retErr = errors.Join(retErr, host.Close(context.WithoutCancel(ctx)))
+		}
+	}()
 
 	host = hostPkg.NewLoggingWrapper(host)
 
@@ -183,6 +256,9 @@ func init() {
 		sshHostKeyAlgorithms = defaultSshHostKeyAlgorithms
 		sshTcpConnectTimeout = defaultSshTcpConnectTimeout
 		docker = defaultDocker
+		dockerImage = defaultDockerImage
+		dockerImageCommit = defaultDockerImageCommit
+		podman = defaultPodman
 		sudo = defaultSudo
 		maxConcurrency = defaultMaxConcurrency
 	})
@@ -8,8 +8,8 @@ import (
 	"github.com/fornellas/resonance/host/types"
 )
 
-func getBaseHostArch(context.Context) types.BaseHost {
-	return nil
+func getBaseHostArch(context.Context) (types.BaseHost, error) {
+	return nil, nil
 }
 
 func addHostFlagsArch(_ *cobra.Command) []string {
@@ -12,11 +12,23 @@ import (
 var localhost bool
 var defaultLocalhost = false
 
-func getBaseHostArch(context.Context) types.BaseHost {
+var chroot string
+var defaultChroot = ""
+
+var nspawn string
+var defaultNspawn = ""
+
+func getBaseHostArch(ctx context.Context) (types.BaseHost, error) {
 	if localhost {
-		return hostPkg.Local{}
+		return hostPkg.Local{}, nil
+	}
+	if chroot != "" {
+		return hostPkg.NewChroot(ctx, chroot)
+	}
+	if nspawn != "" {
+		return hostPkg.NewNspawn(ctx, nspawn)
 	}
-	return nil
+	return nil, nil
 }
 
 func addHostFlagsArch(cmd *cobra.Command) []string {
@@ -28,11 +40,26 @@ func addHostFlagsArch(cmd *cobra.Command) []string {
 	)
 	hostFlagNames = append(hostFlagNames, "host-local")
 
+	cmd.Flags().StringVar(
+		&chroot, "host-chroot", defaultChroot,
+		"Applies configuration to the root filesystem at given directory, using chroot; requires root",
+	)
+	hostFlagNames = append(hostFlagNames, "host-chroot")
+
+	cmd.Flags().StringVar(
+		&nspawn, "host-nspawn", defaultNspawn,
+		"Applies configuration to given running systemd-nspawn container, or other machine registered "+
+			"with systemd-machined, using systemd-run",
+	)
+	hostFlagNames = append(hostFlagNames, "host-nspawn")
+
 	return hostFlagNames
 }
 
 func init() {
 	resetFlagsFns = append(resetFlagsFns, func() {
 		localhost = defaultLocalhost
+		chroot = defaultChroot
+		nspawn = defaultNspawn
 	})
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// failingBaseHost is a types.BaseHost which fails to run commands, and records whether it was
+// closed.
+type failingBaseHost struct {
+	closed bool
+}
+
+func (h *failingBaseHost) Run(ctx context.Context, cmd types.Cmd) (types.WaitStatus, error) {
+	return types.WaitStatus{}, errors.New("failing base host")
+}
+
+func (h *failingBaseHost) String() string {
+	return "failing"
+}
+
+func (h *failingBaseHost) Type() string {
+	return "failing"
+}
+
+func (h *failingBaseHost) Close(ctx context.Context) error {
+	h.closed = true
+	return nil
+}
+
+func TestWrapBaseHost(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+
+	t.Run("sudo failure closes base host", func(t *testing.T) {
+		t.Cleanup(func() { ResetFlags() })
+		sudo = true
+
+		baseHost := &failingBaseHost{}
+		_, _, err := wrapBaseHost(ctx, baseHost)
+		require.ErrorContains(t, err, "failing base host")
+		require.True(t, baseHost.closed)
+	})
+
+	t.Run("agent failure closes base host", func(t *testing.T) {
+		t.Cleanup(func() { ResetFlags() })
+
+		baseHost := &failingBaseHost{}
+		_, _, err := wrapBaseHost(ctx, baseHost)
+		require.ErrorContains(t, err, "failing base host")
+		require.True(t, baseHost.closed)
+	})
+}
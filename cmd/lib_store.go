@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -12,6 +17,7 @@ import (
 
 var storeNameMap = map[string]bool{
 	"remote": true,
+	"http":   true,
 }
 
 type StoreValue struct {
@@ -53,6 +59,212 @@ var storeValue = NewStoreValue()
 var storeHostPath string
 var defaultStoreHostPath = "/var/lib/resonance"
 
+var storeHTTPURL string
+var defaultStoreHTTPURL = ""
+
+// HistorySizeValue is a pflag.Value for the number of history entries to keep at the store, which
+// must be at least 1, as the entry of the apply being saved is always kept.
+type HistorySizeValue struct {
+	size int
+}
+
+func NewHistorySizeValue() *HistorySizeValue {
+	return &HistorySizeValue{size: storePkg.DefaultHistorySize}
+}
+
+func (h *HistorySizeValue) String() string {
+	return strconv.Itoa(h.size)
+}
+
+func (h *HistorySizeValue) Set(value string) error {
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid history size '%s'", value)
+	}
+	if size < 1 {
+		return fmt.Errorf("history size must be at least 1, got %d", size)
+	}
+	h.size = size
+	return nil
+}
+
+func (h *HistorySizeValue) Type() string {
+	return "int"
+}
+
+func (h *HistorySizeValue) Size() int {
+	return h.size
+}
+
+func (h *HistorySizeValue) Reset() {
+	h.size = storePkg.DefaultHistorySize
+}
+
+var storeHistorySize = NewHistorySizeValue()
+
+// ByteSizeValue is a pflag.Value for a size in bytes, optionally with a binary unit suffix, eg:
+// 512, 64K, 10M, 1G.
+type ByteSizeValue struct {
+	size int64
+}
+
+var byteSizeUnits = map[string]int64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+func (b *ByteSizeValue) String() string {
+	return strconv.FormatInt(b.size, 10)
+}
+
+func (b *ByteSizeValue) Set(value string) error {
+	numberStr := strings.TrimRight(value, "KMGT")
+	multiplier, ok := byteSizeUnits[value[len(numberStr):]]
+	if !ok {
+		return fmt.Errorf("invalid size unit at '%s', valid units are K, M, G or T", value)
+	}
+	number, err := strconv.ParseInt(numberStr, 10, 64)
+	if err != nil || number < 0 {
+		return fmt.Errorf("invalid size '%s'", value)
+	}
+	if number > math.MaxInt64/multiplier {
+		return fmt.Errorf("size '%s' is too large", value)
+	}
+	b.size = number * multiplier
+	return nil
+}
+
+func (b *ByteSizeValue) Type() string {
+	return "size"
+}
+
+func (b *ByteSizeValue) Size() int64 {
+	return b.size
+}
+
+func (b *ByteSizeValue) Reset() {
+	b.size = 0
+}
+
+var storeLogCount int
+
+var storeLogMaxAge time.Duration
+
+var storeLogMaxSize = &ByteSizeValue{}
+
+var storeEncryptionKeyFile string
+var defaultStoreEncryptionKeyFile = ""
+
+var storeEncryptionAllowUnencrypted bool
+var defaultStoreEncryptionAllowUnencrypted = false
+
+// storeEncryptionKeyEnv is the environment variable from which the store encryption key is read,
+// when no key file is given.
+var storeEncryptionKeyEnv = "RESONANCE_STORE_ENCRYPTION_KEY"
+
+// getStoreCipher returns the Cipher for the encryption key from the store encryption key file,
+// or from the environment. If no key is given, nil is returned.
+func getStoreCipher() (*storePkg.Cipher, error) {
+	var keyStr string
+	if storeEncryptionKeyFile != "" {
+		keyBytes, err := os.ReadFile(storeEncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read store encryption key file: %w", err)
+		}
+		keyStr = string(keyBytes)
+	} else {
+		keyStr = os.Getenv(storeEncryptionKeyEnv)
+		if keyStr == "" {
+			return nil, nil
+		}
+	}
+	key, err := storePkg.ParseEncryptionKey(keyStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store encryption key: %w", err)
+	}
+	cipher, err := storePkg.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.AllowUnencrypted = storeEncryptionAllowUnencrypted
+	return cipher, nil
+}
+
+var storeSigningKeyFile string
+var defaultStoreSigningKeyFile = ""
+
+// storeSigningKeyEnv is the environment variable from which the store signing key is read, when
+// no key file is given.
+var storeSigningKeyEnv = "RESONANCE_STORE_SIGNING_KEY"
+
+var storeSkipVerification bool
+var defaultStoreSkipVerification = false
+
+// getStoreIntegrity returns the Integrity from the store flags, with the signing key from the store
+// signing key file, or from the environment.
+func getStoreIntegrity() (storePkg.Integrity, error) {
+	integrity := storePkg.Integrity{
+		SkipVerification: storeSkipVerification,
+	}
+	var keyStr string
+	if storeSigningKeyFile != "" {
+		keyBytes, err := os.ReadFile(storeSigningKeyFile)
+		if err != nil {
+			return integrity, fmt.Errorf("failed to read store signing key file: %w", err)
+		}
+		keyStr = string(keyBytes)
+	} else {
+		keyStr = os.Getenv(storeSigningKeyEnv)
+		if keyStr == "" {
+			return integrity, nil
+		}
+	}
+	signingKey, err := storePkg.ParseSigningKey(keyStr)
+	if err != nil {
+		return integrity, fmt.Errorf("invalid store signing key: %w", err)
+	}
+	integrity.SigningKey = signingKey
+	return integrity, nil
+}
+
+// newHostStore returns a HostStore configured with the store flags.
+func newHostStore(host types.Host, path string) (*storePkg.HostStore, error) {
+	hostStore := storePkg.NewHostStore(host, path)
+	if err := configureHostStore(hostStore); err != nil {
+		return nil, err
+	}
+	return hostStore, nil
+}
+
+// configureHostStore configures hostStore with the store flags.
+func configureHostStore(hostStore *storePkg.HostStore) error {
+	cipher, err := getStoreCipher()
+	if err != nil {
+		return err
+	}
+	integrity, err := getStoreIntegrity()
+	if err != nil {
+		return err
+	}
+	hostStore.HistorySize = storeHistorySize.Size()
+	hostStore.LogRetention = getStoreLogRetention()
+	hostStore.Cipher = cipher
+	hostStore.Integrity = integrity
+	return nil
+}
+
+// getStoreLogRetention returns the LogRetention from the store flags.
+func getStoreLogRetention() storePkg.LogRetention {
+	return storePkg.LogRetention{
+		Count:   storeLogCount,
+		MaxAge:  storeLogMaxAge,
+		MaxSize: storeLogMaxSize.Size(),
+	}
+}
+
 func AddStoreFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().VarP(storeValue, "store", "", "Where to store state information")
 
@@ -61,11 +273,72 @@ func AddStoreFlags(cmd *cobra.Command) {
 		"Path on remote host where to store state",
 	)
 
+	cmd.Flags().StringVarP(
+		&storeHTTPURL, "store-http-url", "", defaultStoreHTTPURL,
+		"URL of a HTTP store service, such as served by store-server, where to store state",
+	)
+
+	cmd.Flags().VarP(
+		storeHistorySize, "store-history-size", "",
+		"Number of previously applied states to keep at the store history, at least 1",
+	)
+
+	cmd.Flags().IntVarP(
+		&storeLogCount, "store-log-count", "", storePkg.DefaultLogRetention.Count,
+		"Maximum number of session logs to keep at the store. Zero means no limit.",
+	)
+
+	cmd.Flags().DurationVarP(
+		&storeLogMaxAge, "store-log-max-age", "", storePkg.DefaultLogRetention.MaxAge,
+		"Maximum age of session logs to keep at the store. Zero means no limit.",
+	)
+
+	cmd.Flags().VarP(
+		storeLogMaxSize, "store-log-max-size", "",
+		"Maximum total size of session logs to keep at the store, eg: 512K, 100M. Zero means no limit.",
+	)
+
+	cmd.Flags().StringVarP(
+		&storeEncryptionKeyFile, "store-encryption-key-file", "", defaultStoreEncryptionKeyFile,
+		fmt.Sprintf(
+			"File with a base64 encoded 32 bytes key, eg: from `head -c 32 /dev/urandom | base64`, "+
+				"used to encrypt state and logs at the store. The key can also be given with the %s "+
+				"environment variable.",
+			storeEncryptionKeyEnv,
+		),
+	)
+
+	cmd.Flags().BoolVarP(
+		&storeEncryptionAllowUnencrypted, "store-encryption-allow-unencrypted", "",
+		defaultStoreEncryptionAllowUnencrypted,
+		"Read state, history and logs from the store which are not encrypted, with a warning, "+
+			"when an encryption key is given. Use it to enable encryption for an existing store: "+
+			"state is encrypted when next saved, but history and logs saved before remain not "+
+			"encrypted until pruned.",
+	)
+
+	cmd.Flags().StringVarP(
+		&storeSigningKeyFile, "store-signing-key-file", "", defaultStoreSigningKeyFile,
+		fmt.Sprintf(
+			"File with a base64 encoded 32 bytes ed25519 private key seed, eg: from "+
+				"`head -c 32 /dev/urandom | base64`, used to sign state at the store, which is then "+
+				"required to be signed when loaded. The key can also be given with the %s "+
+				"environment variable.",
+			storeSigningKeyEnv,
+		),
+	)
+
+	cmd.Flags().BoolVarP(
+		&storeSkipVerification, "store-skip-verification", "", defaultStoreSkipVerification,
+		"Accept state from the store which fails checksum or signature verification, as when it "+
+			"was modified outside resonance. Use only after reviewing the changes.",
+	)
+
 	addStoreFlagsArch(cmd)
 }
 
-func GetStore(host types.Host) (storePkg.Store, string, error) {
-	store, config, err := getStoreArch(storeValue.String())
+func GetStore(ctx context.Context, host types.Host) (storePkg.Store, string, error) {
+	store, config, err := getStoreArch(ctx, storeValue.String(), host)
 	if err != nil {
 		return nil, "", err
 	}
@@ -75,9 +348,29 @@ func GetStore(host types.Host) (storePkg.Store, string, error) {
 
 	switch storeValue.String() {
 	case "remote":
-		return storePkg.NewLoggingWrapper(
-			storePkg.NewHostStore(host, storeHostPath),
-		), storeHostPath, nil
+		hostStore, err := newHostStore(host, storeHostPath)
+		if err != nil {
+			return nil, "", err
+		}
+		return storePkg.NewLoggingWrapper(hostStore), storeHostPath, nil
+	case "http":
+		httpStore, err := storePkg.NewHTTPStore(storeHTTPURL, host.String())
+		if err != nil {
+			return nil, "", err
+		}
+		cipher, err := getStoreCipher()
+		if err != nil {
+			return nil, "", err
+		}
+		integrity, err := getStoreIntegrity()
+		if err != nil {
+			return nil, "", err
+		}
+		httpStore.HistorySize = storeHistorySize.Size()
+		httpStore.LogRetention = getStoreLogRetention()
+		httpStore.Cipher = cipher
+		httpStore.Integrity = integrity
+		return storePkg.NewLoggingWrapper(httpStore), storeHTTPURL, nil
 	default:
 		panic("bug: unexpected store value")
 	}
@@ -87,5 +380,14 @@ func init() {
 	resetFlagsFns = append(resetFlagsFns, func() {
 		storeValue.Reset()
 		storeHostPath = defaultStoreHostPath
+		storeHTTPURL = defaultStoreHTTPURL
+		storeHistorySize.Reset()
+		storeLogCount = storePkg.DefaultLogRetention.Count
+		storeLogMaxAge = storePkg.DefaultLogRetention.MaxAge
+		storeLogMaxSize.Reset()
+		storeEncryptionKeyFile = defaultStoreEncryptionKeyFile
+		storeEncryptionAllowUnencrypted = defaultStoreEncryptionAllowUnencrypted
+		storeSigningKeyFile = defaultStoreSigningKeyFile
+		storeSkipVerification = defaultStoreSkipVerification
 	})
 }
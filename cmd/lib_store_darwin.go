@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
+
 	"github.com/spf13/cobra"
 
+	"github.com/fornellas/resonance/host/types"
 	storePkg "github.com/fornellas/resonance/store"
 )
 
 func addStoreFlagsArch(cmd *cobra.Command) {}
 
-func getStoreArch(storeType string) (storePkg.Store, string, error) {
+func getStoreArch(ctx context.Context, storeType string, hst types.Host) (storePkg.Store, string, error) {
 	return nil, "", nil
 }
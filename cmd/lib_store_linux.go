@@ -1,39 +1,86 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
 
 	"github.com/fornellas/resonance/host"
+	"github.com/fornellas/resonance/host/types"
 	storePkg "github.com/fornellas/resonance/store"
 )
 
 var storeLocalhostPath string
 var defaultStoreLocalhostPath = "state/"
 
-func addStoreFlagsArch(cmd *cobra.Command) {
+var storeGitPath string
+var defaultStoreGitPath = "state-git/"
+
+func addStoreLocalPathFlag(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(
 		&storeLocalhostPath, "store-local-path", "", defaultStoreLocalhostPath,
-		"Path on localhost where to store state",
+		"Path on localhost where to store state, with a directory per host",
+	)
+}
+
+func addStoreFlagsArch(cmd *cobra.Command) {
+	addStoreLocalPathFlag(cmd)
+
+	cmd.Flags().StringVarP(
+		&storeGitPath, "store-git-path", "", defaultStoreGitPath,
+		"Path on localhost of a git repository where to store state, with a directory per host",
 	)
 }
 
-func getStoreArch(storeType string) (storePkg.Store, string, error) {
-	if storeType == "local" {
-		storeLocalhostPathAbs, err := filepath.Abs(storeLocalhostPath)
+// getStoreLocalPathAbs returns the absolute path for the store local path flag.
+func getStoreLocalPathAbs() (string, error) {
+	storeLocalhostPathAbs, err := filepath.Abs(storeLocalhostPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for store local path: %w", err)
+	}
+	return storeLocalhostPathAbs, nil
+}
+
+func getStoreArch(ctx context.Context, storeType string, hst types.Host) (storePkg.Store, string, error) {
+	switch storeType {
+	case "local":
+		storeLocalhostPathAbs, err := getStoreLocalPathAbs()
+		if err != nil {
+			return nil, "", err
+		}
+		hostIdentity, err := storePkg.GetHostIdentity(ctx, hst)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get host identity: %w", err)
+		}
+		localStore, err := storePkg.NewLocalStore(ctx, host.Local{}, storeLocalhostPathAbs, hostIdentity)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to get absolute path for store local path: %w", err)
+			return nil, "", err
+		}
+		if err := configureHostStore(localStore.HostStore); err != nil {
+			return nil, "", err
+		}
+		return localStore, storeLocalhostPathAbs, nil
+	case "git":
+		storeGitPathAbs, err := filepath.Abs(storeGitPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get absolute path for store git path: %w", err)
+		}
+		gitStore := storePkg.NewGitStore(host.Local{}, storeGitPathAbs, hst.String())
+		if err := configureHostStore(gitStore.HostStore); err != nil {
+			return nil, "", err
 		}
-		return storePkg.NewHostStore(host.Local{}, storeLocalhostPathAbs), storeLocalhostPathAbs, nil
+		return gitStore, storeGitPathAbs, nil
 	}
 	return nil, "", nil
 }
 
 func init() {
 	storeNameMap["local"] = true
+	storeNameMap["git"] = true
 	resetFlagsFns = append(resetFlagsFns, func() {
 		storeLocalhostPath = defaultStoreLocalhostPath
+		storeGitPath = defaultStoreGitPath
 	})
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreEncryption(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	keyFilePath := filepath.Join(dir, "key")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, os.WriteFile(keyFilePath, []byte(key+"\n"), 0600))
+
+	wrongKeyFilePath := filepath.Join(dir, "wrong_key")
+	wrongKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, os.WriteFile(wrongKeyFilePath, []byte(wrongKey), 0600))
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+	encryptedStoreArgs := append(append([]string{}, storeArgs...), "--store-encryption-key-file", keyFilePath)
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, encryptedStoreArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	t.Run("encrypted", func(t *testing.T) {
+		stateBytes, err := os.ReadFile(filepath.Join(getLocalStoreHostPath(t, storePath), "state", "v1", "state.yaml"))
+		require.NoError(t, err)
+		require.NotContains(t, string(stateBytes), filePath)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"history"}, encryptedStoreArgs...),
+			ExpectStdoutContains: []string{"\n1 ", "sha256:"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, encryptedStoreArgs...),
+			ExpectStderrContains: []string{"All resources in sync with last applied state"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv(storeEncryptionKeyEnv, key)
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectStderrContains: []string{"All resources in sync with last applied state"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: append(
+				[]string{"drift"}, append(storeArgs, "--store-encryption-key-file", wrongKeyFilePath)...,
+			),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"wrong encryption key"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"history"}, storeArgs...),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"an encryption key is required"},
+		}
+		cmd.Run(t)
+	})
+}
+
+func TestStoreEncryptionAllowUnencrypted(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filepath.Join(dir, "file"), os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	keyFilePath := filepath.Join(dir, "key")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, os.WriteFile(keyFilePath, []byte(key), 0600))
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+	encryptedStoreArgs := append(append([]string{}, storeArgs...), "--store-encryption-key-file", keyFilePath)
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	cmd = TestCmd{
+		Args:                 append([]string{"drift"}, encryptedStoreArgs...),
+		ExpectedCode:         1,
+		ExpectStderrContains: []string{"data is not encrypted, but an encryption key was given"},
+	}
+	cmd.Run(t)
+
+	cmd = TestCmd{
+		Args: append(
+			append([]string{"apply"}, encryptedStoreArgs...),
+			"--store-encryption-allow-unencrypted", blueprintPath,
+		),
+		ExpectStderrContains: []string{"Reading data which is not encrypted"},
+	}
+	cmd.Run(t)
+
+	stateBytes, err := os.ReadFile(filepath.Join(getLocalStoreHostPath(t, storePath), "state", "v1", "state.yaml"))
+	require.NoError(t, err)
+	require.NotContains(t, string(stateBytes), "regular_file")
+
+	cmd = TestCmd{
+		Args:                 append([]string{"drift"}, encryptedStoreArgs...),
+		ExpectStderrContains: []string{"All resources in sync with last applied state"},
+	}
+	cmd.Run(t)
+}
+
+func TestStoreIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	keyFilePath := filepath.Join(dir, "key")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, os.WriteFile(keyFilePath, []byte(key+"\n"), 0600))
+
+	storeArgs := []string{
+		"--host-local", "--store", "local", "--store-local-path", storePath,
+		"--store-signing-key-file", keyFilePath,
+	}
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	statePath := filepath.Join(getLocalStoreHostPath(t, storePath), "state", "v1", "state.yaml")
+	stateBytes, err := os.ReadFile(statePath)
+	require.NoError(t, err)
+	require.Contains(t, string(stateBytes), "# resonance-signature: ")
+	require.NoError(t, os.WriteFile(
+		statePath, bytes.Replace(stateBytes, []byte("regular_file: foo"), []byte("regular_file: bar"), 1), 0600,
+	))
+
+	t.Run("tampered", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"failed integrity verification: checksum mismatch", "--store-skip-verification"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("skip verification", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: append(
+				append([]string{"apply"}, append(storeArgs, "--store-skip-verification")...), blueprintPath,
+			),
+			ExpectStderrContains: []string{"Skipping failed integrity verification"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("sealed again", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"drift"}, storeArgs...),
+			ExpectStderrContains: []string{"All resources in sync with last applied state"},
+		}
+		cmd.Run(t)
+	})
+}
+
+func TestStoreHistorySize(t *testing.T) {
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", t.TempDir()}
+	for _, historySize := range []string{"0", "-1"} {
+		t.Run(historySize, func(t *testing.T) {
+			t.Cleanup(func() { ResetFlags() })
+			RootCmd.SetArgs(append([]string{"history", "--store-history-size", historySize}, storeArgs...))
+			require.ErrorContains(t, RootCmd.Execute(), "history size must be at least 1")
+		})
+	}
+}
+
+func TestByteSizeValue(t *testing.T) {
+	byteSizeValue := &ByteSizeValue{}
+
+	require.NoError(t, byteSizeValue.Set("10M"))
+	require.Equal(t, int64(10<<20), byteSizeValue.Size())
+
+	require.NoError(t, byteSizeValue.Set("8388607T"))
+	require.Equal(t, int64(8388607<<40), byteSizeValue.Size())
+
+	require.ErrorContains(t, byteSizeValue.Set("8388608T"), "too large")
+	require.ErrorContains(t, byteSizeValue.Set("9999999999T"), "too large")
+	require.ErrorContains(t, byteSizeValue.Set("-1"), "invalid size")
+	require.ErrorContains(t, byteSizeValue.Set("1X"), "invalid size")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	slogxtCobra "github.com/fornellas/slogxt/cobra"
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/sessionlog"
+)
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Browse session logs.",
+	Long:  "Browse session logs kept at the store.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.MustLogger(cmd.Context())
+		if err := cmd.Help(); err != nil {
+			logger.Error("failed to display help", "error", err)
+			Exit(1)
+		}
+	},
+}
+
+var LogsListCmd = &cobra.Command{
+	Use:   "list [flags]",
+	Short: "List session logs.",
+	Long:  "List all session logs kept at the store, oldest first.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroup(cmd.Context(), "📃 Logs")
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		logInfos, err := store.ListLogs(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to list logs: %w", err))
+			return
+		}
+
+		tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "NAME\tTIME\tSIZE")
+		for _, logInfo := range logInfos {
+			fmt.Fprintf(
+				tabWriter, "%s\t%s\t%d\n",
+				logInfo.Name, logInfo.ModTime.Local().Format(time.RFC3339), logInfo.Size,
+			)
+		}
+		if err := tabWriter.Flush(); err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+	},
+}
+
+var LogsCatCmd = &cobra.Command{
+	Use:   "cat [flags] name",
+	Short: "Print a session log.",
+	Long:  "Print the uncompressed contents of a session log kept at the store to stdout.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "📃 Logs", "name", name)
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		logReadCloser, err := store.GetLogReadCloser(ctx, name)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to read log: %w", err))
+			return
+		}
+		defer func() {
+			if err := logReadCloser.Close(); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close log: %w", err))
+			}
+		}()
+
+		if _, err := io.Copy(os.Stdout, logReadCloser); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to read log: %w", err))
+			return
+		}
+	},
+}
+
+var LogsReplayCmd = &cobra.Command{
+	Use:   "replay [flags] name",
+	Short: "Replay a session log.",
+	Long: "Render a session log kept at the store to stderr, as its session was originally " +
+		"logged, honoring log flags.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "📃 Logs", "name", name)
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		logReadCloser, err := store.GetLogReadCloser(ctx, name)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to read log: %w", err))
+			return
+		}
+		defer func() {
+			if err := logReadCloser.Close(); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close log: %w", err))
+			}
+		}()
+
+		replayHandler := slogxtCobra.GetLogger(cmd.OutOrStderr()).Handler()
+		if err := sessionlog.Replay(ctx, logReadCloser, replayHandler); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to replay log: %w", err))
+			return
+		}
+	},
+}
+
+func init() {
+	AddHostFlags(LogsListCmd)
+	AddStoreFlags(LogsListCmd)
+	LogsCmd.AddCommand(LogsListCmd)
+
+	AddHostFlags(LogsCatCmd)
+	AddStoreFlags(LogsCatCmd)
+	LogsCmd.AddCommand(LogsCatCmd)
+
+	AddHostFlags(LogsReplayCmd)
+	AddStoreFlags(LogsReplayCmd)
+	LogsCmd.AddCommand(LogsReplayCmd)
+
+	RootCmd.AddCommand(LogsCmd)
+}
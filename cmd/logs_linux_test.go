@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogs(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	storeArgs := []string{"--host-local", "--store", "local", "--store-local-path", storePath}
+
+	cmd := TestCmd{
+		Args: append(append([]string{"apply"}, storeArgs...), blueprintPath),
+	}
+	cmd.Run(t)
+
+	dirEntries, err := os.ReadDir(filepath.Join(getLocalStoreHostPath(t, storePath), "logs"))
+	require.NoError(t, err)
+	require.Len(t, dirEntries, 1)
+	name := strings.TrimSuffix(dirEntries[0].Name(), ".gz")
+
+	t.Run("list", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append([]string{"logs", "list"}, storeArgs...),
+			ExpectStdoutContains: []string{"NAME", name},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("cat", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"logs", "cat"}, storeArgs...), name),
+			ExpectStdoutContains: []string{"Apply successful"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("cat non-existent", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"logs", "cat"}, storeArgs...), "non-existent"),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"failed to read log"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"logs", "replay"}, storeArgs...), name),
+			ExpectStderrContains: []string{"version", "Apply", "Apply successful", "in_sync"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("replay non-existent", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 append(append([]string{"logs", "replay"}, storeArgs...), "non-existent"),
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{"failed to read log"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		cmd := TestCmd{
+			Args: append(append([]string{"apply"}, storeArgs...), "--store-log-max-size", "1", blueprintPath),
+		}
+		cmd.Run(t)
+
+		dirEntries, err := os.ReadDir(filepath.Join(getLocalStoreHostPath(t, storePath), "logs"))
+		require.NoError(t, err)
+		require.Len(t, dirEntries, 1)
+	})
+}
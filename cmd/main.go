@@ -1,14 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/fornellas/resonance/resources"
 )
 
 func main() {
 	log.SetFlags(0)
-	if err := RootCmd.Execute(); err != nil {
+
+	// Interrupting cancels the context, so that commands stop; clean up, such as rolling back,
+	// unlocking the store and closing the host, is done without cancellation. Interrupting again
+	// terminates right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,45 +1,88 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
 
 	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	planPkg "github.com/fornellas/resonance/plan"
 )
 
+// PlanChangesExitCode is the exit code used by plan when there are pending changes.
+var PlanChangesExitCode = 2
+
 var PlanCmd = &cobra.Command{
 	Use:   "plan [flags] [file|dir]",
 	Short: "Plan actions.",
-	Long:  "Load resources from file/dir and plan which actions are required to apply them.",
-	Args:  cobra.ExactArgs(1),
+	Long: "Load resources from file/dir and plan which actions are required to apply them.\n\n" +
+		fmt.Sprintf(
+			"Exits with code %d when there are pending changes, 1 on errors, or 0 when all resources are in sync.",
+			PlanChangesExitCode,
+		),
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
 
 		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "📝 Planning", "path", path)
 
 		var retErr error
+		var hasChanges bool
 		defer func() {
 			if retErr != nil {
 				logger.Error("Failed", "err", retErr)
 				Exit(1)
 			}
+			if hasChanges {
+				Exit(PlanChangesExitCode)
+			}
 		}()
 
+		blueprint, err := blueprintPkg.LoadPath(ctx, path)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load blueprint: %w", err))
+			return
+		}
+
 		host, ctx, err := GetHost(ctx)
 		if err != nil {
 			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
 			return
 		}
 		defer func() {
-			if err := host.Close(ctx); err != nil {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
 				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
 			}
 		}()
 		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
 
-		panic("TODO")
+		plan, err := planPkg.NewPlan(ctx, host, blueprint)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to plan: %w", err))
+			return
+		}
+
+		for _, resourcePlan := range plan {
+			_, logger := log.MustWithGroupAttrs(
+				ctx, resourcePlan.Desired.TypeName, "source", resourcePlan.Desired.Source(),
+			)
+			if resourcePlan.HasChanges() {
+				logger.Info("🔧 Changes pending", "diff", log.NewTerminalValue(resourcePlan.Diff().TerminalString()))
+			} else {
+				logger.Info("✅ In sync")
+			}
+		}
+
+		if plan.HasChanges() {
+			hasChanges = true
+			logger.Info("🔧 Changes pending")
+		} else {
+			logger.Info("🎆 All resources in sync")
+		}
 	},
 }
 
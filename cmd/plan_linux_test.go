@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+
+	filePath := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(filePath, []byte("foo"), 0600))
+
+	writeBlueprint := func(t *testing.T, contents string) string {
+		blueprintPath := filepath.Join(t.TempDir(), "blueprint.yaml")
+		require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+			"- File:\n    path: %s\n    regular_file: %s\n    mode: 0600\n    uid: %d\n    gid: %d\n",
+			filePath, contents, os.Getuid(), os.Getgid(),
+		)), 0600))
+		return blueprintPath
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"plan", "--host-local", writeBlueprint(t, "foo")},
+			ExpectStderrContains: []string{"All resources in sync"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("changes pending", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"plan", "--host-local", writeBlueprint(t, "bar")},
+			ExpectedCode:         PlanChangesExitCode,
+			ExpectStderrContains: []string{"Changes pending", "regular_file: bar"},
+		}
+		cmd.Run(t)
+	})
+}
+
+func TestPlanDockerImageCommit(t *testing.T) {
+	blueprintPath := filepath.Join(t.TempDir(), "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte("[]\n"), 0600))
+
+	t.Cleanup(func() { ResetFlags() })
+	RootCmd.SetArgs([]string{
+		"plan", "--host-docker-image", "debian:trixie", "--host-docker-image-commit", "example:tag",
+		blueprintPath,
+	})
+	require.ErrorContains(t, RootCmd.Execute(), "unknown flag: --host-docker-image-commit")
+}
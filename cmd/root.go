@@ -54,10 +54,22 @@ var resetFlagsFns = []func(){
 	func() { slogxtCobra.Reset() },
 }
 
+// resetFlagsChanged marks all flags of cmd and its sub commands as not changed, so that mutually
+// exclusive flags set previously are not accounted for.
+func resetFlagsChanged(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		flag.Changed = false
+	})
+	for _, subCmd := range cmd.Commands() {
+		resetFlagsChanged(subCmd)
+	}
+}
+
 func ResetFlags() {
 	for _, resetFlagFn := range resetFlagsFns {
 		resetFlagFn()
 	}
+	resetFlagsChanged(RootCmd)
 }
 
 func init() {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -33,7 +34,7 @@ var RunCmd = &cobra.Command{
 			return
 		}
 		defer func() {
-			if err := host.Close(ctx); err != nil {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
 				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
 			}
 		}()
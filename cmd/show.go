@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+var ShowCmd = &cobra.Command{
+	Use:   "show [flags] id",
+	Short: "Show a previous apply.",
+	Long: "Print a previous apply from the store history to stdout, with its metadata and the " +
+		"state saved by it. Use the history command to list available ids.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroupAttrs(cmd.Context(), "📜 Show", "id", args[0])
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		host, ctx, err := GetHost(ctx)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			return
+		}
+		defer func() {
+			if err := host.Close(context.WithoutCancel(ctx)); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
+			}
+		}()
+		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
+
+		store, storeConfig, err := GetStore(ctx, host)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get store: %w", err))
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "store", fmt.Sprintf("%s %s", storeValue.String(), storeConfig))
+
+		historyEntry, err := loadHistoryEntry(ctx, store, args[0])
+		if err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+
+		historyEntryBytes, err := yaml.Marshal(historyEntry)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to marshal: %w", err))
+			return
+		}
+		fmt.Print(string(historyEntryBytes))
+	},
+}
+
+func init() {
+	AddHostFlags(ShowCmd)
+
+	AddStoreFlags(ShowCmd)
+
+	RootCmd.AddCommand(ShowCmd)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host"
+	storePkg "github.com/fornellas/resonance/store"
+)
+
+var StoreCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage the local store.",
+	Long:  "Manage the local store, which keeps state and logs for multiple hosts.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.MustLogger(cmd.Context())
+		if err := cmd.Help(); err != nil {
+			logger.Error("failed to display help", "error", err)
+			Exit(1)
+		}
+	},
+}
+
+var StoreLsCmd = &cobra.Command{
+	Use:   "ls [flags]",
+	Short: "List hosts at the local store.",
+	Long:  "List all hosts with state or logs kept at the local store.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroup(cmd.Context(), "🗄️ Store")
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		storeLocalhostPathAbs, err := getStoreLocalPathAbs()
+		if err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+		ctx, _ = log.MustWithAttrs(ctx, "path", storeLocalhostPathAbs)
+
+		hostIdentities, err := storePkg.ListLocalStoreHosts(ctx, host.Local{}, storeLocalhostPathAbs)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to list hosts: %w", err))
+			return
+		}
+
+		tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "HOST\tMACHINE ID")
+		for _, hostIdentity := range hostIdentities {
+			machineID := hostIdentity.MachineID
+			if machineID == "" {
+				machineID = "-"
+			}
+			fmt.Fprintf(tabWriter, "%s\t%s\n", hostIdentity.Host, machineID)
+		}
+		if err := tabWriter.Flush(); err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+	},
+}
+
+func init() {
+	addStoreLocalPathFlag(StoreLsCmd)
+	StoreCmd.AddCommand(StoreLsCmd)
+
+	RootCmd.AddCommand(StoreCmd)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreLs(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+
+	filePath := filepath.Join(dir, "file")
+
+	blueprintPath := filepath.Join(dir, "blueprint.yaml")
+	require.NoError(t, os.WriteFile(blueprintPath, []byte(fmt.Sprintf(
+		"- File:\n    path: %s\n    regular_file: foo\n    uid: %d\n    gid: %d\n",
+		filePath, os.Getuid(), os.Getgid(),
+	)), 0600))
+
+	t.Run("empty", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"store", "ls", "--store-local-path", storePath},
+			ExpectStdoutContains: []string{"HOST", "MACHINE ID"},
+		}
+		cmd.Run(t)
+	})
+
+	cmd := TestCmd{
+		Args: []string{
+			"apply", "--host-local", "--store", "local", "--store-local-path", storePath, blueprintPath,
+		},
+	}
+	cmd.Run(t)
+
+	t.Run("host", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"store", "ls", "--store-local-path", storePath},
+			ExpectStdoutContains: []string{"HOST", "\nlocalhost "},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(storePath, "state", "v1"), 0700))
+		cmd := TestCmd{
+			Args: []string{
+				"drift", "--host-local", "--store", "local", "--store-local-path", storePath,
+			},
+			ExpectStderrContains: []string{"Found state from before the local store was namespaced per host"},
+		}
+		cmd.Run(t)
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fornellas/slogxt/log"
+
+	storePkg "github.com/fornellas/resonance/store"
+)
+
+var storeServerListen string
+var defaultStoreServerListen = "localhost:8080"
+
+var storeServerPath string
+var defaultStoreServerPath = "store/"
+
+var StoreServerCmd = &cobra.Command{
+	Use:   "store-server [flags]",
+	Short: "Serve a HTTP store.",
+	Long: "Serve the API used by the http store from a local directory, with a directory per " +
+		"host, until interrupted.",
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, logger := log.MustWithGroupAttrs(
+			cmd.Context(), "🗄️ Store server", "listen", storeServerListen, "path", storeServerPath,
+		)
+
+		var retErr error
+		defer func() {
+			if retErr != nil {
+				logger.Error("Failed", "err", retErr)
+				Exit(1)
+			}
+		}()
+
+		path, err := filepath.Abs(storeServerPath)
+		if err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to get absolute path: %w", err))
+			return
+		}
+		if err := os.MkdirAll(path, 0700); err != nil {
+			retErr = errors.Join(retErr, err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		server := &http.Server{
+			Addr:    storeServerListen,
+			Handler: storePkg.NewHTTPStoreHandler(path),
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- server.ListenAndServe()
+		}()
+		logger.Info("Serving")
+
+		select {
+		case err := <-errCh:
+			retErr = errors.Join(retErr, err)
+			return
+		case <-ctx.Done():
+		}
+
+		logger.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("failed to shutdown: %w", err))
+			return
+		}
+	},
+}
+
+func init() {
+	StoreServerCmd.Flags().StringVarP(
+		&storeServerListen, "listen", "", defaultStoreServerListen,
+		"Address to listen on, in the format [host]:port",
+	)
+
+	StoreServerCmd.Flags().StringVarP(
+		&storeServerPath, "path", "", defaultStoreServerPath,
+		"Local directory where to store state",
+	)
+
+	RootCmd.AddCommand(StoreServerCmd)
+
+	resetFlagsFns = append(resetFlagsFns, func() {
+		storeServerListen = defaultStoreServerListen
+		storeServerPath = defaultStoreServerPath
+	})
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
 )
 
 var ValidateCmd = &cobra.Command{
 	Use:   "validate [flags] [file|dir]",
 	Short: "Validates resource files.",
-	Long:  "Loads all resoures from yaml files, validating whether they are ok.",
+	Long:  "Loads all resoures from yaml files, validating whether they are ok. No host connection is required.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
@@ -27,24 +29,16 @@ var ValidateCmd = &cobra.Command{
 			}
 		}()
 
-		host, ctx, err := GetHost(ctx)
+		blueprint, err := blueprintPkg.LoadPath(ctx, path)
 		if err != nil {
-			retErr = errors.Join(retErr, fmt.Errorf("failed to get host: %w", err))
+			retErr = errors.Join(retErr, fmt.Errorf("failed to load blueprint: %w", err))
 			return
 		}
-		defer func() {
-			if err := host.Close(ctx); err != nil {
-				retErr = errors.Join(retErr, fmt.Errorf("failed to close host: %w", err))
-			}
-		}()
-		ctx, _ = log.MustWithAttrs(ctx, "host", fmt.Sprintf("%s => %s", host.Type(), host.String()))
 
-		panic("TODO")
+		logger.Info("🎆 Blueprint is valid", "resources", len(blueprint.Entries))
 	},
 }
 
 func init() {
-	AddHostFlags(ValidateCmd)
-
 	RootCmd.AddCommand(ValidateCmd)
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+
+	validPath := filepath.Join(dir, "valid.yaml")
+	require.NoError(t, os.WriteFile(validPath, []byte("- APTPackage:\n    package: vim\n"), 0600))
+
+	invalidPath := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("- APTPackage:\n    package: '!'\n"), 0600))
+
+	t.Run("valid", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"validate", validPath},
+			ExpectStderrContains: []string{"Blueprint is valid"},
+		}
+		cmd.Run(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cmd := TestCmd{
+			Args:                 []string{"validate", invalidPath},
+			ExpectedCode:         1,
+			ExpectStderrContains: []string{invalidPath + ":1: APTPackage: invalid package"},
+		}
+		cmd.Run(t)
+	})
+}
@@ -4,10 +4,12 @@ package diff
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/fornellas/slogxt/ansi"
 	"github.com/kylelemons/godebug/diff"
+	"gopkg.in/yaml.v3"
 )
 
 // Chunks represents a collection of chunks that describe the difference between two
@@ -17,6 +19,20 @@ import (
 // ANSI colors are used unless color.NoColor is set.
 type Chunks []diff.Chunk
 
+func marshalYaml(v any) []string {
+	bytes, err := yaml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("bug: failed to marshal to YAML: %s", err))
+	}
+	return strings.Split(string(bytes), "\n")
+}
+
+// DiffAsYaml marshals both a and b as YAML and returns the Chunks describing how to go from
+// a to b.
+func DiffAsYaml(a, b any) Chunks {
+	return Chunks(diff.DiffChunks(marshalYaml(a), marshalYaml(b)))
+}
+
 // HasChanges return true when the chunks contains changes.
 func (c Chunks) HasChanges() bool {
 	for _, chunk := range c {
@@ -7,6 +7,36 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestDiffAsYaml(t *testing.T) {
+	type value struct {
+		Name  string `yaml:"name"`
+		Value int    `yaml:"value"`
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		chunks := DiffAsYaml(&value{Name: "foo", Value: 1}, &value{Name: "foo", Value: 1})
+		require.False(t, chunks.HasChanges())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		chunks := DiffAsYaml(&value{Name: "foo", Value: 1}, &value{Name: "foo", Value: 2})
+		require.True(t, chunks.HasChanges())
+		added := []string{}
+		deleted := []string{}
+		for _, chunk := range chunks {
+			added = append(added, chunk.Added...)
+			deleted = append(deleted, chunk.Deleted...)
+		}
+		require.Equal(t, []string{"value: 2"}, added)
+		require.Equal(t, []string{"value: 1"}, deleted)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		chunks := DiffAsYaml(nil, &value{Name: "foo"})
+		require.True(t, chunks.HasChanges())
+	})
+}
+
 func TestChunks(t *testing.T) {
 	t.Run("HasChanges", func(t *testing.T) {
 		t.Run("empty_chunks", func(t *testing.T) {
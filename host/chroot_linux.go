@@ -0,0 +1,191 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// chrootMount is a pseudo-filesystem mounted inside the chroot, that commands commonly expect to
+// be available (eg: package managers).
+type chrootMount struct {
+	target string
+	source string
+	fstype string
+	flags  uintptr
+	// unmountFlags are the flags used to unmount it at Close.
+	unmountFlags int
+}
+
+var chrootMounts = []chrootMount{
+	{target: "/proc", source: "proc", fstype: "proc"},
+	{target: "/sys", source: "sysfs", fstype: "sysfs"},
+	{target: "/dev", source: "/dev", flags: syscall.MS_BIND | syscall.MS_REC, unmountFlags: syscall.MNT_DETACH},
+}
+
+// Chroot runs commands inside a directory holding a root filesystem, using chroot(2), so that
+// root filesystems (eg: built with debootstrap) can be configured offline. Running it requires
+// root privileges.
+//
+// For the lifetime of the host, /proc, /sys and /dev are mounted inside the root filesystem, if not
+// already mounted there.
+type Chroot struct {
+	// Path to the directory holding the root filesystem.
+	Path string
+	// mounted holds the chrootMounts mounted by NewChroot, to be unmounted at Close.
+	mounted []chrootMount
+}
+
+// NewChroot creates a new Chroot at given path.
+func NewChroot(ctx context.Context, path string) (*Chroot, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fileInfo.IsDir() {
+		return nil, &fs.PathError{Op: "NewChroot", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	h := &Chroot{
+		Path: path,
+	}
+	if err := h.mount(ctx); err != nil {
+		return nil, errors.Join(err, h.Close(ctx))
+	}
+	return h, nil
+}
+
+// isMountPoint returns whether path is a mount point, by comparing its device with the device of
+// its parent directory.
+func isMountPoint(path string) (bool, error) {
+	var stat, parentStat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return false, &fs.PathError{Op: "Stat", Path: path, Err: err}
+	}
+	parent := filepath.Dir(path)
+	if err := syscall.Stat(parent, &parentStat); err != nil {
+		return false, &fs.PathError{Op: "Stat", Path: parent, Err: err}
+	}
+	return stat.Dev != parentStat.Dev, nil
+}
+
+func (h *Chroot) mount(ctx context.Context) error {
+	logger := log.MustLogger(ctx)
+	for _, chrootMount := range chrootMounts {
+		target := filepath.Join(h.Path, chrootMount.target)
+		if _, err := os.Stat(target); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.Warn("Mount point missing at root filesystem, not mounting", "path", target)
+				continue
+			}
+			return err
+		}
+		mountPoint, err := isMountPoint(target)
+		if err != nil {
+			return err
+		}
+		if mountPoint {
+			continue
+		}
+		logger.Debug("Mounting", "source", chrootMount.source, "target", target)
+		if err := syscall.Mount(
+			chrootMount.source, target, chrootMount.fstype, chrootMount.flags, "",
+		); err != nil {
+			return fmt.Errorf("failed to mount %s at %s: %w", chrootMount.source, target, err)
+		}
+		h.mounted = append(h.mounted, chrootMount)
+	}
+	return nil
+}
+
+func (h *Chroot) Run(ctx context.Context, cmd types.Cmd) (types.WaitStatus, error) {
+	if cmd.Dir == "" {
+		cmd.Dir = "/tmp"
+	}
+	if !filepath.IsAbs(cmd.Dir) {
+		return types.WaitStatus{}, &fs.PathError{
+			Op:   "Run",
+			Path: cmd.Dir,
+			Err:  errors.New("path must be absolute"),
+		}
+	}
+
+	if len(cmd.Env) == 0 {
+		cmd.Env = types.DefaultEnv
+	}
+
+	// The command path must be looked up inside the chroot, which env does for us.
+	args := []string{"-i"}
+	args = append(args, cmd.Env...)
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args...)
+
+	execCmd := exec.CommandContext(ctx, "/usr/bin/env", args...)
+	execCmd.Dir = cmd.Dir
+	execCmd.Env = []string{}
+	execCmd.SysProcAttr = &syscall.SysProcAttr{Chroot: h.Path}
+	execCmd.Stdin = cmd.Stdin
+	execCmd.Stdout = cmd.Stdout
+	execCmd.Stderr = cmd.Stderr
+
+	err := execCmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return types.WaitStatus{}, err
+		}
+	}
+
+	// env returns 127 when the command does not exist
+	if execCmd.ProcessState.Exited() && execCmd.ProcessState.ExitCode() == 127 {
+		return types.WaitStatus{}, os.ErrNotExist
+	}
+
+	waitStatus := types.WaitStatus{}
+	waitStatus.ExitCode = uint32(execCmd.ProcessState.ExitCode())
+	waitStatus.Exited = execCmd.ProcessState.Exited()
+	signal := execCmd.ProcessState.Sys().(syscall.WaitStatus).Signal()
+	if signal > 0 {
+		waitStatus.Signal = signal.String()
+	}
+	return waitStatus, nil
+}
+
+func (h *Chroot) String() string {
+	return h.Path
+}
+
+func (h *Chroot) Type() string {
+	return "chroot"
+}
+
+// Close unmounts all pseudo-filesystems mounted by NewChroot.
+func (h *Chroot) Close(ctx context.Context) error {
+	logger := log.MustLogger(ctx)
+	var err error
+	mounted := []chrootMount{}
+	for i := len(h.mounted) - 1; i >= 0; i-- {
+		m := h.mounted[i]
+		target := filepath.Join(h.Path, m.target)
+		logger.Debug("Unmounting", "target", target)
+		if unmountErr := syscall.Unmount(target, m.unmountFlags); unmountErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unmount %s: %w", target, unmountErr))
+			mounted = append([]chrootMount{m}, mounted...)
+		}
+	}
+	h.mounted = mounted
+	return err
+}
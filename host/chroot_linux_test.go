@@ -0,0 +1,24 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+func TestChroot(t *testing.T) {
+	if !isRoot(t) {
+		t.Skip("chroot requires root")
+	}
+
+	ctx := t.Context()
+	ctx = log.WithTestLogger(ctx)
+
+	host, err := NewChroot(ctx, "/")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, host.Close(ctx)) }()
+
+	testBaseHost(t, host, "/", "chroot")
+}
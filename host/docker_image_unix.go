@@ -0,0 +1,204 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// runDocker runs the docker command with given arguments, returning its stdout.
+func runDocker(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	stdout := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(
+			"docker %s: %w:\nstdout:\n%s\nstderr:\n%s",
+			strings.Join(args, " "), err, stdout.String(), stderr.String(),
+		)
+	}
+	return stdout.String(), nil
+}
+
+// DockerImageContainerLabel is the label set at containers started by NewDockerImage, so that
+// containers left behind, eg: when resonance is killed, can be removed with:
+//
+//	docker rm --force $(docker ps --all --quiet --filter label=io.github.fornellas.resonance.ephemeral=true)
+//
+// It is cleared at committed images, so that containers started from them do not match.
+const DockerImageContainerLabel = "io.github.fornellas.resonance.ephemeral"
+
+// DockerImage is a Docker host, for an ephemeral container created from an image. The container is
+// removed on Close, optionally committing it to a new image before.
+type DockerImage struct {
+	Docker
+	// Image the container is created from.
+	Image string
+	// CommitTag, when set, makes Close commit the container to an image with this tag, before
+	// removing it.
+	CommitTag string
+	// CommitLabels are labels set at the image committed on Close.
+	CommitLabels map[string]string
+	// Container is the name of the container.
+	Container string
+	// ImageID is the ID of the image committed on Close.
+	ImageID string
+}
+
+// NewDockerImage starts a new container from an image, in the format
+// "[<name|uid>[:<group|gid>]@]<image>" (eg: root@debian:trixie), and returns a DockerImage host
+// targeting it. The container runs sleep, and does not start the image entrypoint or command. If
+// commitTag is set, the container is committed to an image with this tag on Close, with the image
+// entrypoint and command restored.
+func NewDockerImage(ctx context.Context, image, commitTag string) (*DockerImage, error) {
+	user, image, err := parseContainerConnectionString(image)
+	if err != nil {
+		return nil, err
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	container := fmt.Sprintf("resonance-%s", hex.EncodeToString(idBytes))
+
+	logger := log.MustLogger(ctx)
+	logger.Info("🐳 Starting container", "image", image, "container", container)
+	if _, err := runDocker(
+		ctx, "run",
+		"--detach",
+		"--name", container,
+		"--label", DockerImageContainerLabel+"=true",
+		"--entrypoint", "sleep",
+		image,
+		"infinity",
+	); err != nil {
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	dockerHst, err := NewDocker(ctx, fmt.Sprintf("%s@%s", user, container))
+	if err != nil {
+		return nil, errors.Join(err, removeDockerContainer(ctx, container))
+	}
+
+	return &DockerImage{
+		Docker:       dockerHst,
+		Image:        image,
+		CommitTag:    commitTag,
+		CommitLabels: map[string]string{},
+		Container:    container,
+	}, nil
+}
+
+// removeDockerContainer removes container, even if ctx is cancelled, as it happens on interrupt.
+func removeDockerContainer(ctx context.Context, container string) error {
+	if _, err := runDocker(context.WithoutCancel(ctx), "rm", "--force", container); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// getCommitChanges returns the Dockerfile instructions to restore the image entrypoint and command
+// at the committed image, as these were overridden to run sleep.
+func (h *DockerImage) getCommitChanges(ctx context.Context) ([]string, error) {
+	configJSON, err := runDocker(ctx, "image", "inspect", "--format", "{{json .Config}}", h.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	var config struct {
+		Entrypoint []string
+		Cmd        []string
+	}
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	changes := []string{}
+	for _, instruction := range []struct {
+		name  string
+		value []string
+	}{
+		{"ENTRYPOINT", config.Entrypoint},
+		{"CMD", config.Cmd},
+	} {
+		value := instruction.value
+		if value == nil {
+			value = []string{}
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("%s %s", instruction.name, valueJSON))
+	}
+	changes = append(changes, fmt.Sprintf("LABEL %q=\"\"", DockerImageContainerLabel))
+
+	labels := make([]string, 0, len(h.CommitLabels))
+	for key := range h.CommitLabels {
+		labels = append(labels, key)
+	}
+	sort.Strings(labels)
+	for _, key := range labels {
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := json.Marshal(h.CommitLabels[key])
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("LABEL %s=%s", keyJSON, valueJSON))
+	}
+
+	return changes, nil
+}
+
+func (h *DockerImage) commit(ctx context.Context) error {
+	changes, err := h.getCommitChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"commit"}
+	for _, change := range changes {
+		args = append(args, "--change", change)
+	}
+	args = append(args, h.Container, h.CommitTag)
+
+	stdout, err := runDocker(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("failed to commit container: %w", err)
+	}
+	h.ImageID = strings.TrimSpace(stdout)
+
+	logger := log.MustLogger(ctx)
+	logger.Info("📦 Committed container", "tag", h.CommitTag, "id", h.ImageID)
+
+	return nil
+}
+
+func (h *DockerImage) String() string {
+	return h.Image
+}
+
+// Close commits the container, if CommitTag is set, and removes it.
+func (h *DockerImage) Close(ctx context.Context) error {
+	var err error
+	if h.CommitTag != "" {
+		err = h.commit(ctx)
+	}
+	logger := log.MustLogger(ctx)
+	logger.Info("🗑️ Removing container", "container", h.Container)
+	return errors.Join(err, removeDockerContainer(ctx, h.Container), h.Docker.Close(ctx))
+}
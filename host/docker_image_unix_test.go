@@ -0,0 +1,103 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// fakeDocker is a docker replacement, which logs all its invocations to $FAKE_DOCKER_LOG, and runs
+// docker exec commands locally at the working directory, ignoring the user and container.
+var fakeDocker = `#!/bin/sh
+set -e
+echo "$*" >> "$FAKE_DOCKER_LOG"
+case "$1" in
+	run)
+		echo 0123456789abcdef
+		;;
+	exec)
+		shift
+		while [ "${1#--}" != "$1" ] ; do
+			case "$1" in
+				--workdir)
+					shift
+					cd "$1"
+					;;
+				--user)
+					shift
+					;;
+			esac
+			shift
+		done
+		shift
+		exec "$@"
+		;;
+	image)
+		echo '{"Entrypoint":null,"Cmd":["bash"]}'
+		;;
+	commit)
+		echo sha256:fedcba9876543210
+		;;
+	rm)
+		;;
+	*)
+		exit 1
+		;;
+esac
+`
+
+func TestDockerImage(t *testing.T) {
+	ctx := t.Context()
+	ctx = log.WithTestLogger(ctx)
+
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "docker"), []byte(fakeDocker), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	dockerLogPath := filepath.Join(t.TempDir(), "docker.log")
+	t.Setenv("FAKE_DOCKER_LOG", dockerLogPath)
+
+	dockerImageHost, err := NewDockerImage(ctx, "debian:trixie", "example:tag")
+	require.NoError(t, err)
+	dockerImageHost.CommitLabels["org.example.label"] = "value"
+
+	testBaseHost(t, dockerImageHost, "debian:trixie", "docker")
+
+	require.NoError(t, dockerImageHost.Close(ctx))
+	require.Equal(t, "sha256:fedcba9876543210", dockerImageHost.ImageID)
+
+	dockerLogBytes, err := os.ReadFile(dockerLogPath)
+	require.NoError(t, err)
+	dockerLog := string(dockerLogBytes)
+	container := dockerImageHost.Container
+	require.Contains(t, dockerLog, fmt.Sprintf(
+		"run --detach --name %s --label %s=true --entrypoint sleep debian:trixie infinity\n",
+		container, DockerImageContainerLabel,
+	))
+	require.Contains(t, dockerLog, fmt.Sprintf(
+		"commit --change ENTRYPOINT [] --change CMD [\"bash\"] --change LABEL %q=\"\" "+
+			"--change LABEL \"org.example.label\"=\"value\" %s example:tag\n",
+		DockerImageContainerLabel, container,
+	))
+	require.Contains(t, dockerLog, fmt.Sprintf("rm --force %s\n", container))
+
+	t.Run("Close cancelled", func(t *testing.T) {
+		require.NoError(t, os.Remove(dockerLogPath))
+
+		dockerImageHost, err := NewDockerImage(ctx, "debian:trixie", "")
+		require.NoError(t, err)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		require.NoError(t, dockerImageHost.Close(cancelledCtx))
+
+		dockerLogBytes, err := os.ReadFile(dockerLogPath)
+		require.NoError(t, err)
+		require.Contains(t, string(dockerLogBytes), fmt.Sprintf("rm --force %s\n", dockerImageHost.Container))
+	})
+}
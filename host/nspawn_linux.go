@@ -0,0 +1,98 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// Nspawn uses systemd-run to target a running systemd-nspawn container, or any other machine
+// registered with systemd-machined (see machinectl list).
+type Nspawn struct {
+	// Machine name, as in machinectl list.
+	Machine string
+}
+
+func NewNspawn(ctx context.Context, machine string) (Nspawn, error) {
+	nspawnHst := Nspawn{
+		Machine: machine,
+	}
+	return nspawnHst, nil
+}
+
+func (h Nspawn) Run(ctx context.Context, cmd types.Cmd) (types.WaitStatus, error) {
+	if cmd.Dir == "" {
+		cmd.Dir = "/tmp"
+	}
+	if !filepath.IsAbs(cmd.Dir) {
+		return types.WaitStatus{}, &fs.PathError{
+			Op:   "Run",
+			Path: cmd.Dir,
+			Err:  errors.New("path must be absolute"),
+		}
+	}
+
+	if len(cmd.Env) == 0 {
+		cmd.Env = types.DefaultEnv
+	}
+
+	args := []string{
+		"--machine", h.Machine,
+		"--quiet",
+		"--wait",
+		"--pipe",
+		"--collect",
+		"--service-type", "exec",
+		"--working-directory", cmd.Dir,
+		"--",
+		"/usr/bin/env", "-i",
+	}
+	args = append(args, cmd.Env...)
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args...)
+
+	execCmd := exec.CommandContext(ctx, "systemd-run", args...)
+	execCmd.Stdin = cmd.Stdin
+	execCmd.Stdout = cmd.Stdout
+	execCmd.Stderr = cmd.Stderr
+
+	err := execCmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return types.WaitStatus{}, err
+		}
+	}
+
+	// systemd-run propagates the exit code of the command, and env returns 127 when the command
+	// does not exist, so that's the quick way we can detect os.ErrNotExist here.
+	if execCmd.ProcessState.Exited() && execCmd.ProcessState.ExitCode() == 127 {
+		return types.WaitStatus{}, os.ErrNotExist
+	}
+
+	waitStatus := types.WaitStatus{}
+	waitStatus.ExitCode = uint32(execCmd.ProcessState.ExitCode())
+	waitStatus.Exited = execCmd.ProcessState.Exited()
+	signal := execCmd.ProcessState.Sys().(syscall.WaitStatus).Signal()
+	if signal > 0 {
+		waitStatus.Signal = signal.String()
+	}
+	return waitStatus, nil
+}
+
+func (h Nspawn) String() string {
+	return h.Machine
+}
+
+func (h Nspawn) Type() string {
+	return "nspawn"
+}
+
+func (h Nspawn) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// fakeSystemdRun is a systemd-run replacement, which logs all its invocations to
+// $FAKE_SYSTEMD_RUN_LOG, and runs the command locally at the working directory, as
+// systemd-run --wait --pipe would at the machine.
+var fakeSystemdRun = `#!/bin/sh
+set -e
+echo "$*" >> "$FAKE_SYSTEMD_RUN_LOG"
+while [ "$1" != "--" ] ; do
+	if [ "$1" = "--working-directory" ] ; then
+		shift
+		cd "$1"
+	fi
+	shift
+done
+shift
+exec "$@"
+`
+
+func TestNspawn(t *testing.T) {
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "systemd-run"), []byte(fakeSystemdRun), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	systemdRunLogPath := filepath.Join(t.TempDir(), "systemd-run.log")
+	t.Setenv("FAKE_SYSTEMD_RUN_LOG", systemdRunLogPath)
+
+	nspawnHost, err := NewNspawn(t.Context(), "machine")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, nspawnHost.Close(t.Context())) }()
+
+	testBaseHost(t, nspawnHost, "machine", "nspawn")
+
+	t.Run("systemd-run arguments", func(t *testing.T) {
+		require.NoError(t, os.Remove(systemdRunLogPath))
+
+		waitStatus, err := nspawnHost.Run(t.Context(), types.Cmd{
+			Path: "true",
+			Args: []string{"foo", "bar"},
+			Env:  []string{"FOO=bar"},
+			Dir:  "/",
+		})
+		require.NoError(t, err)
+		require.True(t, waitStatus.Success())
+
+		waitStatus, err = nspawnHost.Run(t.Context(), types.Cmd{Path: "true"})
+		require.NoError(t, err)
+		require.True(t, waitStatus.Success())
+
+		systemdRunLogBytes, err := os.ReadFile(systemdRunLogPath)
+		require.NoError(t, err)
+		require.Equal(t,
+			"--machine machine --quiet --wait --pipe --collect --service-type exec "+
+				"--working-directory / -- /usr/bin/env -i FOO=bar true foo bar\n"+
+				"--machine machine --quiet --wait --pipe --collect --service-type exec "+
+				"--working-directory /tmp -- /usr/bin/env -i "+strings.Join(types.DefaultEnv, " ")+" true\n",
+			string(systemdRunLogBytes),
+		)
+	})
+}
@@ -0,0 +1,113 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// Podman uses podman exec to target a running container. It works with both rootful and rootless
+// Podman, as podman is run as the current user, with its environment, so that rootless Podman
+// finds the user's containers from XDG_RUNTIME_DIR and its storage configuration. With rootless
+// Podman:
+//   - Containers are only visible to the user which created them, so resonance must be run as that
+//     user, and not with sudo.
+//   - Users at the connection string are from the container's user namespace: root, the default,
+//     maps to the user running podman at the host.
+type Podman struct {
+	// User/group and container in the format "[<name|uid>[:<group|gid>]@]<container>" (eg: root@ubuntu)
+	ConnectionString string
+}
+
+func NewPodman(ctx context.Context, connection string) (Podman, error) {
+	if _, _, err := parseContainerConnectionString(connection); err != nil {
+		return Podman{}, err
+	}
+	podmanHst := Podman{
+		ConnectionString: connection,
+	}
+	return podmanHst, nil
+}
+
+func (h Podman) Run(ctx context.Context, cmd types.Cmd) (types.WaitStatus, error) {
+	podmanConnectionUser, podmanConnectionContainer, err := parseContainerConnectionString(h.ConnectionString)
+	if err != nil {
+		return types.WaitStatus{}, err
+	}
+
+	if cmd.Dir == "" {
+		cmd.Dir = "/tmp"
+	}
+	if !filepath.IsAbs(cmd.Dir) {
+		return types.WaitStatus{}, &fs.PathError{
+			Op:   "Run",
+			Path: cmd.Dir,
+			Err:  errors.New("path must be absolute"),
+		}
+	}
+
+	if len(cmd.Env) == 0 {
+		cmd.Env = types.DefaultEnv
+	}
+
+	args := []string{"exec"}
+	if cmd.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+	args = append(args, []string{"--user", podmanConnectionUser}...)
+	args = append(args, []string{"--workdir", cmd.Dir}...)
+	args = append(args, podmanConnectionContainer)
+
+	// The command is run with env directly, instead of wrapped with sh as with Docker, so that no
+	// quoting is required, and containers without a shell work. podman exec passes the container
+	// environment to the command, so env -i is required to get only the requested environment.
+	args = append(args, "env", "-i")
+	args = append(args, cmd.Env...)
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args...)
+
+	execCmd := exec.CommandContext(ctx, "podman", args...)
+	execCmd.Stdin = cmd.Stdin
+	execCmd.Stdout = cmd.Stdout
+	execCmd.Stderr = cmd.Stderr
+
+	err = execCmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return types.WaitStatus{}, err
+		}
+	}
+
+	// podman exec returns 127 when env does not exist, and env returns 127 when the command does not
+	// exist, so either way, that's the quick way we can detect os.ErrNotExist here.
+	if execCmd.ProcessState.Exited() && execCmd.ProcessState.ExitCode() == 127 {
+		return types.WaitStatus{}, os.ErrNotExist
+	}
+
+	waitStatus := types.WaitStatus{}
+	waitStatus.ExitCode = uint32(execCmd.ProcessState.ExitCode())
+	waitStatus.Exited = execCmd.ProcessState.Exited()
+	signal := execCmd.ProcessState.Sys().(syscall.WaitStatus).Signal()
+	if signal > 0 {
+		waitStatus.Signal = signal.String()
+	}
+	return waitStatus, nil
+}
+
+func (h Podman) String() string {
+	return h.ConnectionString
+}
+
+func (h Podman) Type() string {
+	return "podman"
+}
+
+func (h Podman) Close(ctx context.Context) error {
+	return nil
+}
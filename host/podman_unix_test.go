@@ -0,0 +1,105 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// fakePodman is a podman replacement, which logs all its invocations, along with
+// $XDG_RUNTIME_DIR, to $FAKE_PODMAN_LOG, and runs podman exec commands locally at the working
+// directory, ignoring the user and container.
+var fakePodman = `#!/bin/sh
+set -e
+echo "XDG_RUNTIME_DIR=$XDG_RUNTIME_DIR $*" >> "$FAKE_PODMAN_LOG"
+[ "$1" = "exec" ]
+shift
+while [ "${1#--}" != "$1" ] ; do
+	case "$1" in
+		--workdir)
+			shift
+			cd "$1"
+			;;
+		--user)
+			shift
+			;;
+	esac
+	shift
+done
+shift
+exec "$@"
+`
+
+func TestPodman(t *testing.T) {
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "podman"), []byte(fakePodman), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	podmanLogPath := filepath.Join(t.TempDir(), "podman.log")
+	t.Setenv("FAKE_PODMAN_LOG", podmanLogPath)
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	t.Run("Invalid connection string", func(t *testing.T) {
+		_, err := NewPodman(t.Context(), "user@container@foo")
+		require.ErrorContains(t, err, "invalid connection string format")
+	})
+
+	connection := "1000:1000@container"
+	podmanHost, err := NewPodman(t.Context(), connection)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, podmanHost.Close(t.Context())) }()
+
+	testBaseHost(t, podmanHost, connection, "podman")
+
+	t.Run("podman arguments", func(t *testing.T) {
+		require.NoError(t, os.Remove(podmanLogPath))
+
+		waitStatus, err := podmanHost.Run(t.Context(), types.Cmd{
+			Path:  "true",
+			Args:  []string{"foo", "bar"},
+			Env:   []string{"FOO=bar"},
+			Dir:   "/",
+			Stdin: strings.NewReader(""),
+		})
+		require.NoError(t, err)
+		require.True(t, waitStatus.Success())
+
+		waitStatus, err = podmanHost.Run(t.Context(), types.Cmd{Path: "true"})
+		require.NoError(t, err)
+		require.True(t, waitStatus.Success())
+
+		podmanLogBytes, err := os.ReadFile(podmanLogPath)
+		require.NoError(t, err)
+		require.Equal(t,
+			"XDG_RUNTIME_DIR=/run/user/1000 exec --interactive --user 1000:1000 --workdir / container "+
+				"env -i FOO=bar true foo bar\n"+
+				"XDG_RUNTIME_DIR=/run/user/1000 exec --user 1000:1000 --workdir /tmp container "+
+				"env -i "+strings.Join(types.DefaultEnv, " ")+" true\n",
+			string(podmanLogBytes),
+		)
+	})
+
+	t.Run("rootless", func(t *testing.T) {
+		require.NoError(t, os.Remove(podmanLogPath))
+
+		// Without a user, commands run as root at the container, which with rootless Podman is
+		// the user running podman at the host, which must find the container at its own storage
+		rootlessPodmanHost, err := NewPodman(t.Context(), "container")
+		require.NoError(t, err)
+		waitStatus, err := rootlessPodmanHost.Run(t.Context(), types.Cmd{Path: "true"})
+		require.NoError(t, err)
+		require.True(t, waitStatus.Success())
+
+		podmanLogBytes, err := os.ReadFile(podmanLogPath)
+		require.NoError(t, err)
+		require.Equal(t,
+			"XDG_RUNTIME_DIR=/run/user/1000 exec --user 0:0 --workdir /tmp container "+
+				"env -i "+strings.Join(types.DefaultEnv, " ")+" true\n",
+			string(podmanLogBytes),
+		)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"syscall"
 
 	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v3"
 )
 
 // File mode bits 07777, see inode(7).
@@ -22,6 +23,15 @@ func (f FileMode) LogValue() slog.Value {
 	return slog.StringValue(f.String())
 }
 
+// MarshalYAML marshals as an octal integer, eg: 0644.
+func (f FileMode) MarshalYAML() (any, error) {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!int",
+		Value: f.String(),
+	}, nil
+}
+
 // Mask for all file mode bits.
 var FileModeBitsMask FileMode = 07777
 
@@ -0,0 +1,212 @@
+// Planning of actions required to apply a Blueprint.
+package plan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/diff"
+	"github.com/fornellas/resonance/host/types"
+	"github.com/fornellas/resonance/resources"
+)
+
+// ResourcePlan holds both the desired and the current state of a single resource.
+type ResourcePlan struct {
+	// Desired is the resolved Blueprint entry.
+	Desired *blueprintPkg.Entry
+	// Current is the resource state, as loaded from the host.
+	Current resources.Resource
+	// Exact is set when Desired holds the full state of the resource, as previously loaded from
+	// the host, instead of a Blueprint entry, where unset fields are not managed.
+	Exact bool
+}
+
+// HasChanges returns true when the current state does not satisfy the desired state. For Exact
+// plans, it returns true when the current state is not exactly the desired state.
+func (r *ResourcePlan) HasChanges() bool {
+	if r.Exact {
+		return r.Diff().HasChanges()
+	}
+	return !r.Current.Satisfies(r.Desired.Resource)
+}
+
+// Diff returns the changes required to go from the current to the desired state.
+func (r *ResourcePlan) Diff() diff.Chunks {
+	return diff.DiffAsYaml(r.Current, r.Desired.Resource)
+}
+
+// Plan holds a ResourcePlan for each Blueprint entry, in the same order they were declared.
+type Plan []*ResourcePlan
+
+// NewPlan resolves the given Blueprint and loads the current state of all its resources from
+// host.
+func NewPlan(ctx context.Context, host types.Host, blueprint *blueprintPkg.Blueprint) (Plan, error) {
+	if err := blueprint.Resolve(ctx, host); err != nil {
+		return nil, fmt.Errorf("failed to resolve: %w", err)
+	}
+
+	current, err := blueprint.Load(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load: %w", err)
+	}
+
+	plan := make(Plan, len(blueprint.Entries))
+	for i, entry := range blueprint.Entries {
+		plan[i] = &ResourcePlan{
+			Desired: entry,
+			Current: current.Entries[i].Resource,
+		}
+	}
+	return plan, nil
+}
+
+// NewExactPlan is the same as NewPlan, but for a Blueprint holding the full state of resources, as
+// previously loaded from a host (eg: Plan.Current). Each resource has changes unless its current
+// state is exactly the same.
+func NewExactPlan(ctx context.Context, host types.Host, blueprint *blueprintPkg.Blueprint) (Plan, error) {
+	plan, err := NewPlan(ctx, host, blueprint)
+	if err != nil {
+		return nil, err
+	}
+	for _, resourcePlan := range plan {
+		resourcePlan.Exact = true
+	}
+	return plan, nil
+}
+
+// Current returns a Blueprint with the current state of all resources, as loaded from the host.
+func (p Plan) Current() *blueprintPkg.Blueprint {
+	current := &blueprintPkg.Blueprint{}
+	for _, resourcePlan := range p {
+		current.Entries = append(current.Entries, &blueprintPkg.Entry{
+			TypeName: resourcePlan.Desired.TypeName,
+			Resource: resourcePlan.Current,
+			Path:     resourcePlan.Desired.Path,
+			Line:     resourcePlan.Desired.Line,
+		})
+	}
+	return current
+}
+
+// HasChanges returns true when any of the resources has pending changes.
+func (p Plan) HasChanges() bool {
+	for _, resourcePlan := range p {
+		if resourcePlan.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceGroup(resourcePlan *ResourcePlan) resources.ResourceGroup {
+	groupResource, ok := resourcePlan.Desired.Resource.(resources.GroupResource)
+	if !ok {
+		return nil
+	}
+	return groupResource.Group()
+}
+
+// steps groups resource plans in the order they must be applied: adjacent GroupResource entries
+// of the same ResourceGroup are grouped together, while all other resources are on their own.
+func (p Plan) steps() []Plan {
+	steps := []Plan{}
+	for i := 0; i < len(p); i++ {
+		step := Plan{p[i]}
+		if group := resourceGroup(p[i]); group != nil {
+			for i+1 < len(p) && resourceGroup(p[i+1]) == group {
+				i++
+				step = append(step, p[i])
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func applyStep(ctx context.Context, host types.Host, changed Plan) error {
+	sources := []string{}
+	for _, resourcePlan := range changed {
+		sources = append(sources, resourcePlan.Desired.Source())
+	}
+	source := strings.Join(sources, ", ")
+	ctx, logger := log.MustWithGroupAttrs(ctx, changed[0].Desired.TypeName, "source", source)
+	logger.Info("🔧 Applying")
+
+	var err error
+	if group := resourceGroup(changed[0]); group != nil {
+		groupResources := []resources.GroupResource{}
+		for _, resourcePlan := range changed {
+			groupResources = append(groupResources, resourcePlan.Desired.Resource.(resources.GroupResource))
+		}
+		err = group.Apply(ctx, host, groupResources)
+	} else {
+		err = changed[0].Desired.Resource.(resources.SingleResource).Apply(ctx, host)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", source, changed[0].Desired.TypeName, err)
+	}
+	return nil
+}
+
+// Apply all resources with pending changes, in the same order they were declared. Adjacent
+// GroupResource entries (eg: APTPackage) are applied together with a single ResourceGroup.Apply
+// call, so that changes are transactional; all other resources are applied one by one.
+// It returns all resources that were touched, including the ones that failed to apply.
+func (p Plan) Apply(ctx context.Context, host types.Host) (Plan, error) {
+	applied := Plan{}
+	for _, step := range p.steps() {
+		changed := Plan{}
+		for _, resourcePlan := range step {
+			if resourcePlan.HasChanges() {
+				changed = append(changed, resourcePlan)
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		applied = append(applied, changed...)
+		if err := applyStep(ctx, host, changed); err != nil {
+			return applied, err
+		}
+	}
+	return applied, nil
+}
+
+// Refresh collects the refreshes required by all given applied resources which implement
+// resources.RefreshTrigger, and runs them all at once, so that each unit is refreshed only once.
+func (p Plan) Refresh(ctx context.Context, host types.Host) error {
+	refreshes := []resources.Refresh{}
+	for _, resourcePlan := range p {
+		refreshTrigger, ok := resourcePlan.Desired.Resource.(resources.RefreshTrigger)
+		if !ok {
+			continue
+		}
+		resourceRefreshes, err := refreshTrigger.Refreshes(ctx, host, resourcePlan.Current)
+		if err != nil {
+			return fmt.Errorf("%s: %s: failed to get refreshes: %w", resourcePlan.Desired.Source(), resourcePlan.Desired.TypeName, err)
+		}
+		refreshes = append(refreshes, resourceRefreshes...)
+	}
+	return resources.ApplyRefreshes(ctx, host, refreshes)
+}
+
+// NewRollbackBlueprint returns a Blueprint that restores all applied resources to their full state
+// before they were applied, in reverse order. It must be planned with NewExactPlan, so that fields
+// not declared at the applied Blueprint are restored as well.
+func NewRollbackBlueprint(applied Plan) *blueprintPkg.Blueprint {
+	rollbackBlueprint := &blueprintPkg.Blueprint{}
+	for i := len(applied) - 1; i >= 0; i-- {
+		resourcePlan := applied[i]
+		rollbackBlueprint.Entries = append(rollbackBlueprint.Entries, &blueprintPkg.Entry{
+			TypeName: resourcePlan.Desired.TypeName,
+			Resource: resourcePlan.Current,
+			Path:     resourcePlan.Desired.Path,
+			Line:     resourcePlan.Desired.Line,
+		})
+	}
+	return rollbackBlueprint
+}
@@ -0,0 +1,294 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	hostPkg "github.com/fornellas/resonance/host"
+	"github.com/fornellas/resonance/host/types"
+	"github.com/fornellas/resonance/resources"
+)
+
+func TestPlan(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	dir := t.TempDir()
+	inSyncPath := filepath.Join(dir, "in-sync")
+	require.NoError(t, os.WriteFile(inSyncPath, []byte("foo"), 0600))
+	changedPath := filepath.Join(dir, "changed")
+	require.NoError(t, os.WriteFile(changedPath, []byte("foo"), 0600))
+
+	uid := uint32(os.Getuid())
+	gid := uint32(os.Getgid())
+	var mode types.FileMode = 0600
+	contents := "foo"
+	newContents := "bar"
+
+	newBlueprint := func(paths ...string) *blueprintPkg.Blueprint {
+		blueprint := &blueprintPkg.Blueprint{}
+		for i, path := range paths {
+			regularFile := &contents
+			if path == changedPath {
+				regularFile = &newContents
+			}
+			blueprint.Entries = append(blueprint.Entries, &blueprintPkg.Entry{
+				TypeName: "File",
+				Resource: &resources.File{
+					Path:        path,
+					RegularFile: regularFile,
+					Mode:        &mode,
+					Uid:         &uid,
+					Gid:         &gid,
+				},
+				Path: "test.yaml",
+				Line: i + 1,
+			})
+		}
+		return blueprint
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		plan, err := NewPlan(ctx, host, newBlueprint(inSyncPath))
+		require.NoError(t, err)
+		require.Len(t, plan, 1)
+		require.False(t, plan.HasChanges())
+		require.False(t, plan[0].Diff().HasChanges())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		plan, err := NewPlan(ctx, host, newBlueprint(inSyncPath, changedPath))
+		require.NoError(t, err)
+		require.Len(t, plan, 2)
+		require.True(t, plan.HasChanges())
+		require.False(t, plan[0].HasChanges())
+		require.True(t, plan[1].HasChanges())
+		require.True(t, plan[1].Diff().HasChanges())
+		require.Equal(t, &resources.File{
+			Path:        changedPath,
+			RegularFile: &contents,
+			Mode:        &mode,
+			Uid:         &uid,
+			Gid:         &gid,
+		}, plan[1].Current)
+
+		current := plan.Current()
+		require.Len(t, current.Entries, 2)
+		require.Equal(t, "File", current.Entries[1].TypeName)
+		require.Equal(t, plan[1].Current, current.Entries[1].Resource)
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		plan, err := NewPlan(ctx, host, newBlueprint(filepath.Join(dir, "absent")))
+		require.NoError(t, err)
+		require.True(t, plan.HasChanges())
+		require.True(t, plan[0].Current.(*resources.File).Absent)
+	})
+}
+
+func TestPlanSteps(t *testing.T) {
+	newResourcePlan := func(typeName string, resource resources.Resource) *ResourcePlan {
+		return &ResourcePlan{
+			Desired: &blueprintPkg.Entry{TypeName: typeName, Resource: resource},
+		}
+	}
+	file1 := newResourcePlan("File", &resources.File{Path: "/foo"})
+	aptPackage1 := newResourcePlan("APTPackage", &resources.APTPackage{Package: "vim"})
+	aptPackage2 := newResourcePlan("APTPackage", &resources.APTPackage{Package: "curl"})
+	dpkgArch := newResourcePlan("DpkgArch", &resources.DpkgArch{})
+	aptPackage3 := newResourcePlan("APTPackage", &resources.APTPackage{Package: "git"})
+	file2 := newResourcePlan("File", &resources.File{Path: "/bar"})
+
+	plan := Plan{file1, aptPackage1, aptPackage2, dpkgArch, aptPackage3, file2}
+	require.Equal(t, []Plan{
+		{file1},
+		{aptPackage1, aptPackage2},
+		{dpkgArch},
+		{aptPackage3},
+		{file2},
+	}, plan.steps())
+}
+
+func TestPlanApply(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	dir := t.TempDir()
+	inSyncPath := filepath.Join(dir, "in-sync")
+	require.NoError(t, os.WriteFile(inSyncPath, []byte("foo"), 0600))
+	changedPath := filepath.Join(dir, "changed")
+	require.NoError(t, os.WriteFile(changedPath, []byte("foo"), 0600))
+	absentPath := filepath.Join(dir, "absent")
+
+	uid := uint32(os.Getuid())
+	gid := uint32(os.Getgid())
+	var mode types.FileMode = 0640
+	contents := "foo"
+	newContents := "bar"
+
+	blueprint := &blueprintPkg.Blueprint{
+		Entries: []*blueprintPkg.Entry{
+			{
+				TypeName: "File",
+				Resource: &resources.File{Path: inSyncPath, RegularFile: &contents, Uid: &uid, Gid: &gid},
+			},
+			{
+				TypeName: "File",
+				Resource: &resources.File{Path: changedPath, RegularFile: &newContents, Mode: &mode, Uid: &uid, Gid: &gid},
+			},
+			{
+				TypeName: "File",
+				Resource: &resources.File{Path: absentPath, RegularFile: &contents, Uid: &uid, Gid: &gid},
+			},
+		},
+	}
+
+	plan, err := NewPlan(ctx, host, blueprint)
+	require.NoError(t, err)
+	require.True(t, plan.HasChanges())
+
+	applied, err := plan.Apply(ctx, host)
+	require.NoError(t, err)
+	require.Equal(t, Plan{plan[1], plan[2]}, applied)
+
+	changedBytes, err := os.ReadFile(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, newContents, string(changedBytes))
+	changedFileInfo, err := os.Stat(changedPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(mode), changedFileInfo.Mode().Perm())
+
+	absentFileInfo, err := os.Stat(absentPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), absentFileInfo.Mode().Perm())
+
+	plan, err = NewPlan(ctx, host, blueprint)
+	require.NoError(t, err)
+	require.False(t, plan.HasChanges())
+}
+
+func TestNewExactPlan(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0600))
+
+	plan, err := NewPlan(ctx, host, &blueprintPkg.Blueprint{
+		Entries: []*blueprintPkg.Entry{
+			{TypeName: "File", Resource: &resources.File{Path: path}},
+		},
+	})
+	require.NoError(t, err)
+	current := plan.Current()
+
+	exactPlan, err := NewExactPlan(ctx, host, current)
+	require.NoError(t, err)
+	require.False(t, exactPlan.HasChanges())
+
+	require.NoError(t, os.Chmod(path, 0640))
+
+	// Mode is not declared, so the plan is in sync, but the exact plan is not
+	contents := "foo"
+	plan, err = NewPlan(ctx, host, &blueprintPkg.Blueprint{
+		Entries: []*blueprintPkg.Entry{
+			{TypeName: "File", Resource: &resources.File{Path: path, RegularFile: &contents}},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, plan.HasChanges())
+
+	exactPlan, err = NewExactPlan(ctx, host, current)
+	require.NoError(t, err)
+	require.True(t, exactPlan.HasChanges())
+}
+
+func TestNewRollbackBlueprint(t *testing.T) {
+	t.Run("File", func(t *testing.T) {
+		contents := "foo"
+		newContents := "new"
+		var mode types.FileMode = 0600
+
+		newResourcePlan := func(path string) *ResourcePlan {
+			return &ResourcePlan{
+				Desired: &blueprintPkg.Entry{
+					TypeName: "File",
+					Resource: &resources.File{Path: path, RegularFile: &newContents},
+					Path:     "test.yaml",
+				},
+				Current: &resources.File{Path: path, RegularFile: &contents, Mode: &mode},
+			}
+		}
+		first := newResourcePlan("/first")
+		second := newResourcePlan("/second")
+
+		rollbackBlueprint := NewRollbackBlueprint(Plan{first, second})
+		require.Len(t, rollbackBlueprint.Entries, 2)
+		require.Equal(t, second.Current, rollbackBlueprint.Entries[0].Resource)
+		require.Equal(t, first.Current, rollbackBlueprint.Entries[1].Resource)
+		require.Equal(t, "test.yaml", rollbackBlueprint.Entries[0].Path)
+	})
+
+	// rollbackHasChanges returns whether the rollback of applied has changes, when the host is at
+	// the failed state.
+	rollbackHasChanges := func(t *testing.T, applied *ResourcePlan, failed resources.Resource) bool {
+		rollbackBlueprint := NewRollbackBlueprint(Plan{applied})
+		require.Len(t, rollbackBlueprint.Entries, 1)
+		rollbackResourcePlan := &ResourcePlan{
+			Desired: rollbackBlueprint.Entries[0],
+			Current: failed,
+			Exact:   true,
+		}
+		return rollbackResourcePlan.HasChanges()
+	}
+
+	t.Run("DpkgArch", func(t *testing.T) {
+		applied := &ResourcePlan{
+			Desired: &blueprintPkg.Entry{
+				TypeName: "DpkgArch",
+				Resource: &resources.DpkgArch{ForeignArchitectures: []string{"arm64"}},
+			},
+			Current: &resources.DpkgArch{ForeignArchitectures: []string{"i386"}},
+		}
+		require.True(t, rollbackHasChanges(
+			t, applied, &resources.DpkgArch{ForeignArchitectures: []string{"arm64", "i386"}},
+		))
+		require.True(t, rollbackHasChanges(
+			t, applied, &resources.DpkgArch{ForeignArchitectures: []string{"arm64"}},
+		))
+		require.False(t, rollbackHasChanges(
+			t, applied, &resources.DpkgArch{ForeignArchitectures: []string{"i386"}},
+		))
+	})
+
+	t.Run("APTPackage", func(t *testing.T) {
+		applied := &ResourcePlan{
+			Desired: &blueprintPkg.Entry{
+				TypeName: "APTPackage",
+				Resource: &resources.APTPackage{Package: "wget", Version: "1.25.0-2", Hold: true},
+			},
+			Current: &resources.APTPackage{Package: "wget", Version: "1.21.4-1", Hold: false},
+		}
+		t.Run("hold", func(t *testing.T) {
+			require.True(t, rollbackHasChanges(
+				t, applied, &resources.APTPackage{Package: "wget", Version: "1.21.4-1", Hold: true},
+			))
+		})
+		t.Run("version", func(t *testing.T) {
+			require.True(t, rollbackHasChanges(
+				t, applied, &resources.APTPackage{Package: "wget", Version: "1.25.0-2", Hold: false},
+			))
+		})
+		t.Run("restored", func(t *testing.T) {
+			require.False(t, rollbackHasChanges(
+				t, applied, &resources.APTPackage{Package: "wget", Version: "1.21.4-1", Hold: false},
+			))
+		})
+	})
+}
@@ -33,31 +33,42 @@ type DebconfAnswer string
 type APTPackage struct {
 	// The name of the package
 	// See https://www.debian.org/doc/debian-policy/ch-controlfields.html#package
-	Package string
+	Package string `yaml:"package"`
 	// Whether to remove the package
-	Absent bool
+	Absent bool `yaml:"absent,omitempty"`
 	// Architectures.
 	// See https://www.debian.org/doc/debian-policy/ch-controlfields.html#architecture
-	Architectures []string
+	Architectures []string `yaml:"architectures,omitempty"`
 	// Package version.
 	// See https://www.debian.org/doc/debian-policy/ch-controlfields.html#version
-	Version string
+	Version string `yaml:"version,omitempty"`
 	// Whether the package should be held to prevent automatic upgrades
-	Hold bool
+	Hold bool `yaml:"hold,omitempty"`
 	// Package debconf selections.
 	// See https://wiki.debian.org/debconf
-	DebconfSelections map[DebconfQuestion]DebconfAnswer
+	DebconfSelections map[DebconfQuestion]DebconfAnswer `yaml:"debconf_selections,omitempty"`
 }
 
 var validDpkgPackageRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9+\-.]{1,}$`)
 
 var validDpkgVersionRegexp = regexp.MustCompile(`^(?:([0-9]+):)?(([0-9][A-Za-z0-9.+~]*)|([0-9][A-Za-z0-9.+~-]*-[A-Za-z0-9+.~]+))$`)
 
+// ID returns the package name.
+func (a *APTPackage) ID() string {
+	return a.Package
+}
+
 // Satisfies returns true only when a satisfies b.
 // Eg: if a defines a package with a name and a specific version, and
 // b specifies a package with the same name, but without a version, then
-// a satisfies b (and b would not satisfy a).
-func (a *APTPackage) Satisfies(b *APTPackage) bool {
+// a satisfies b (and b would not satisfy a). Hold is always compared, as Apply sets the selection
+// of installed packages to either hold or install.
+func (a *APTPackage) Satisfies(resource Resource) bool {
+	b, ok := resource.(*APTPackage)
+	if !ok {
+		return false
+	}
+
 	if a.Package != b.Package {
 		return false
 	}
@@ -66,6 +77,10 @@ func (a *APTPackage) Satisfies(b *APTPackage) bool {
 		return false
 	}
 
+	if a.Hold != b.Hold {
+		return false
+	}
+
 	if len(b.Architectures) > 0 {
 		for _, arch := range b.Architectures {
 			if !slices.Contains(a.Architectures, arch) {
@@ -165,8 +180,55 @@ func (a *APTPackage) Validate() error {
 	return nil
 }
 
+// Group returns the ResourceGroup which manages all APTPackage together.
+func (a *APTPackage) Group() ResourceGroup {
+	return aptPackages
+}
+
+// versionChanges returns true when applying changes the installed version of the package from
+// current. When Version is unset, the installed version changes only when it is installed.
+func (a *APTPackage) versionChanges(current Resource) bool {
+	if a.Absent {
+		return false
+	}
+	currentAPTPackage, ok := current.(*APTPackage)
+	if !ok || currentAPTPackage.Absent {
+		return true
+	}
+	return a.Version != "" && a.Version != currentAPTPackage.Version
+}
+
+// Refreshes returns the refreshes required when the installed version of the package changes: all
+// services it ships are reloaded or restarted. Other changes, such as holding the package or
+// changing its debconf selections, require none.
+func (a *APTPackage) Refreshes(ctx context.Context, host types.Host, current Resource) ([]Refresh, error) {
+	refreshes := []Refresh{}
+	if !a.versionChanges(current) {
+		return refreshes, nil
+	}
+	services, err := getDpkgPackageServices(ctx, host, a.Package)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		refreshes = append(refreshes, Refresh{Action: RefreshActionReloadOrRestart, Unit: service})
+	}
+	return refreshes, nil
+}
+
+// APTPackages manages all APTPackage at once, so that package changes are transactional.
 type APTPackages struct{}
 
+var aptPackages = &APTPackages{}
+
+func toAPTPackages(resources []GroupResource) []*APTPackage {
+	aptPackages := make([]*APTPackage, len(resources))
+	for i, resource := range resources {
+		aptPackages[i] = resource.(*APTPackage)
+	}
+	return aptPackages
+}
+
 var debconfShowRegexp = regexp.MustCompile("^([ *]) (.+):(| (.+))$")
 
 func (a *APTPackages) preparePackageQueries(
@@ -400,7 +462,11 @@ func (a *APTPackages) loadHoldStatus(ctx context.Context, host types.Host, aptPa
 	return nil
 }
 
-func (a *APTPackages) Load(ctx context.Context, host types.Host, aptPackages []*APTPackage) error {
+func (a *APTPackages) Load(ctx context.Context, host types.Host, resources []GroupResource) error {
+	return a.load(ctx, host, toAPTPackages(resources))
+}
+
+func (a *APTPackages) load(ctx context.Context, host types.Host, aptPackages []*APTPackage) error {
 	packageQueries, packageToResource := a.preparePackageQueries(aptPackages)
 
 	stdout, err := a.runDpkgQuery(ctx, host, packageQueries, len(aptPackages))
@@ -472,7 +538,7 @@ func (a *APTPackages) loadCurrentArchitecturesForPackages(ctx context.Context, h
 		return make(map[string][]string), nil
 	}
 
-	if err := a.Load(ctx, host, packagesNeedingArchCheck); err != nil {
+	if err := a.load(ctx, host, packagesNeedingArchCheck); err != nil {
 		return nil, fmt.Errorf("failed to load current state for architecture checking: %w", err)
 	}
 
@@ -671,7 +737,14 @@ func (a *APTPackages) postFixDebconf(ctx context.Context, host types.Host, aptPa
 	return nil
 }
 
-func (a *APTPackages) Apply(ctx context.Context, host types.Host, aptPackages []*APTPackage) (err error) {
+// Resolve is a no-op, as APTPackage requires no resolution.
+func (a *APTPackages) Resolve(ctx context.Context, host types.Host, resources []GroupResource) error {
+	return nil
+}
+
+func (a *APTPackages) Apply(ctx context.Context, host types.Host, resources []GroupResource) (err error) {
+	aptPackages := toAPTPackages(resources)
+
 	debconfEditorPath, err := a.setupDebianFrontendEditor(ctx, host)
 	if err != nil {
 		return err
@@ -694,3 +767,10 @@ func (a *APTPackages) Apply(ctx context.Context, host types.Host, aptPackages []
 
 	return nil
 }
+
+func init() {
+	RegisterResourceType(&ResourceType{
+		Name: "APTPackage",
+		New:  func(id string) Resource { return &APTPackage{Package: id} },
+	})
+}
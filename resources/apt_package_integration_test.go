@@ -15,6 +15,14 @@ import (
 	"github.com/fornellas/resonance/host/types"
 )
 
+func toGroupResources(aptPackages []*APTPackage) []GroupResource {
+	resources := make([]GroupResource, len(aptPackages))
+	for i, aptPackage := range aptPackages {
+		resources[i] = aptPackage
+	}
+	return resources
+}
+
 func TestAPTPackagesIntegration(t *testing.T) {
 	t.Run("Load()", func(t *testing.T) {
 		for _, image := range testDockerImages {
@@ -43,7 +51,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 				})
 				aptPackages := &APTPackages{}
 				nanoPkg := &APTPackage{Package: "nano"}
-				err = aptPackages.Load(ctx, agentHost, []*APTPackage{nanoPkg})
+				err = aptPackages.Load(ctx, agentHost, []GroupResource{nanoPkg})
 				require.NoError(t, err)
 				expectedNano := &APTPackage{
 					Package:       "nano",
@@ -53,7 +61,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 
 				// Absent
 				curlAbsent := &APTPackage{Package: "curl", Absent: true}
-				err = aptPackages.Load(ctx, agentHost, []*APTPackage{curlAbsent})
+				err = aptPackages.Load(ctx, agentHost, []GroupResource{curlAbsent})
 				require.NoError(t, err)
 				expectedCurlAbsent := &APTPackage{
 					Package: "curl",
@@ -70,7 +78,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 					Stdin: strings.NewReader("curl hold\n"),
 				})
 				curlHold := &APTPackage{Package: "curl", Hold: true}
-				err = aptPackages.Load(ctx, agentHost, []*APTPackage{curlHold})
+				err = aptPackages.Load(ctx, agentHost, []GroupResource{curlHold})
 				require.NoError(t, err)
 				version := strings.TrimSpace(runAndRequireSuccess(t, ctx, agentHost, types.Cmd{
 					Path: "/usr/bin/dpkg-query", Args: []string{"-W", "-f", "${Version}", "curl"},
@@ -94,7 +102,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 				tzdataPkg := &APTPackage{
 					Package: "tzdata",
 				}
-				err = aptPackages.Load(ctx, agentHost, []*APTPackage{tzdataPkg})
+				err = aptPackages.Load(ctx, agentHost, []GroupResource{tzdataPkg})
 				require.NoError(t, err)
 				require.Contains(t, tzdataPkg.DebconfSelections, DebconfQuestion("tzdata/Areas"))
 				require.Equal(t, DebconfAnswer("Europe"), tzdataPkg.DebconfSelections["tzdata/Areas"])
@@ -123,7 +131,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "nano",
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					cmd := types.Cmd{
@@ -150,7 +158,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "nano",
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Then remove it
@@ -160,7 +168,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Absent:  true,
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Verify it's removed
@@ -192,7 +200,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "curl",
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Then mix operations: keep wget, remove curl, install nano
@@ -208,7 +216,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "nano", // install new
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Verify wget is still installed
@@ -260,7 +268,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Architectures: []string{arch},
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					cmd = types.Cmd{
@@ -302,7 +310,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Architectures: archs,
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					for _, arch := range archs {
@@ -329,7 +337,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "curl",
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Query the installed version
@@ -348,7 +356,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Hold:    true,
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Check that curl is on hold
@@ -365,7 +373,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							Package: "curl",
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Check that curl is not held
@@ -396,7 +404,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							},
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Verify debconf selections
@@ -428,7 +436,7 @@ func TestAPTPackagesIntegration(t *testing.T) {
 							},
 						},
 					}
-					err = aptPackages.Apply(ctx, agentHost, packages)
+					err = aptPackages.Apply(ctx, agentHost, toGroupResources(packages))
 					require.NoError(t, err)
 
 					// Verify debconf selections
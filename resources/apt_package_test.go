@@ -1,11 +1,33 @@
 package resources
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/resonance/host/types"
 )
 
+// dpkgQueryHost is a types.Host which only runs dpkg-query --listfiles, listing files.
+type dpkgQueryHost struct {
+	types.Host
+	files []string
+}
+
+func (h *dpkgQueryHost) Run(ctx context.Context, cmd types.Cmd) (types.WaitStatus, error) {
+	if cmd.Path != "/usr/bin/dpkg-query" {
+		return types.WaitStatus{}, fmt.Errorf("unexpected command: %s", cmd)
+	}
+	if _, err := io.WriteString(cmd.Stdout, strings.Join(h.files, "\n")+"\n"); err != nil {
+		return types.WaitStatus{}, err
+	}
+	return types.WaitStatus{Exited: true}, nil
+}
+
 func TestAPTPackage(t *testing.T) {
 	t.Run("Satisfies()", func(t *testing.T) {
 		tests := []struct {
@@ -126,7 +148,6 @@ func TestAPTPackage(t *testing.T) {
 				a: &APTPackage{
 					Package: "wget",
 					Version: "1.21.4-1ubuntu4.1",
-					Hold:    true,
 				},
 				b: &APTPackage{
 					Package: "wget",
@@ -239,7 +260,6 @@ func TestAPTPackage(t *testing.T) {
 					Package:       "wget",
 					Architectures: []string{"amd64"},
 					Version:       "1.21.4-1ubuntu4.1",
-					Hold:          true,
 				},
 				b: &APTPackage{
 					Package: "wget",
@@ -258,7 +278,7 @@ func TestAPTPackage(t *testing.T) {
 					Package: "wget",
 					Hold:    false,
 				},
-				expected: true,
+				expected: false,
 			},
 			{
 				name: "hold mismatch - a not held, b held",
@@ -635,4 +655,60 @@ func TestAPTPackage(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Refreshes()", func(t *testing.T) {
+		host := &dpkgQueryHost{files: []string{
+			"/usr/sbin/nginx",
+			"/usr/lib/systemd/system/nginx.service",
+		}}
+		restart := []Refresh{{Action: RefreshActionReloadOrRestart, Unit: "nginx.service"}}
+		tests := []struct {
+			name              string
+			aptPackage        *APTPackage
+			current           *APTPackage
+			expectedRefreshes []Refresh
+		}{
+			{
+				name:              "installed",
+				aptPackage:        &APTPackage{Package: "nginx"},
+				current:           &APTPackage{Package: "nginx", Absent: true},
+				expectedRefreshes: restart,
+			},
+			{
+				name:              "version changed",
+				aptPackage:        &APTPackage{Package: "nginx", Version: "2", Hold: true},
+				current:           &APTPackage{Package: "nginx", Version: "1", Hold: true},
+				expectedRefreshes: restart,
+			},
+			{
+				name:              "hold only",
+				aptPackage:        &APTPackage{Package: "nginx", Version: "1", Hold: true},
+				current:           &APTPackage{Package: "nginx", Version: "1"},
+				expectedRefreshes: []Refresh{},
+			},
+			{
+				name: "debconf selections only",
+				aptPackage: &APTPackage{
+					Package:           "nginx",
+					DebconfSelections: map[DebconfQuestion]DebconfAnswer{"nginx/question": "yes"},
+				},
+				current:           &APTPackage{Package: "nginx", Version: "1"},
+				expectedRefreshes: []Refresh{},
+			},
+			{
+				name:              "removed",
+				aptPackage:        &APTPackage{Package: "nginx", Absent: true},
+				current:           &APTPackage{Package: "nginx", Version: "1"},
+				expectedRefreshes: []Refresh{},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				refreshes, err := tt.aptPackage.Refreshes(t.Context(), host, tt.current)
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedRefreshes, refreshes)
+			})
+		}
+	})
 }
@@ -18,16 +18,34 @@ import (
 // When architectures are removed, all packages for that architecture are purged before removal.
 type DpkgArch struct {
 	// ForeignArchitectures specifies extra architectures dpkg is configured to allow packages to be installed for.
-	ForeignArchitectures []string
+	ForeignArchitectures []string `yaml:"foreign_architectures,omitempty"`
 }
 
-// Satisfies returns true only when a satisfies b.
-func (a *DpkgArch) Satisfies(b *DpkgArch) bool {
+// DpkgArchID is the ID of DpkgArch: as it is a host wide resource, there can only be one.
+const DpkgArchID = "dpkg"
+
+// ID returns DpkgArchID.
+func (a *DpkgArch) ID() string {
+	return DpkgArchID
+}
+
+// Satisfies returns true only when a satisfies b. As Apply removes architectures not listed, a
+// only satisfies b when both have the same set of foreign architectures.
+func (a *DpkgArch) Satisfies(resource Resource) bool {
+	b, ok := resource.(*DpkgArch)
+	if !ok {
+		return false
+	}
 	for _, bArch := range b.ForeignArchitectures {
 		if !slices.Contains(a.ForeignArchitectures, bArch) {
 			return false
 		}
 	}
+	for _, aArch := range a.ForeignArchitectures {
+		if !slices.Contains(b.ForeignArchitectures, aArch) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -136,6 +154,27 @@ func removeExtraArchs(ctx context.Context, host types.Host, current, desired map
 	return nil
 }
 
+// Load the current foreign architectures from host.
+func (a *DpkgArch) Load(ctx context.Context, host types.Host) error {
+	foreignArchsMap, err := getCurrentForeignArchs(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	a.ForeignArchitectures = []string{}
+	for arch := range foreignArchsMap {
+		a.ForeignArchitectures = append(a.ForeignArchitectures, arch)
+	}
+	slices.Sort(a.ForeignArchitectures)
+
+	return nil
+}
+
+// Resolve is a no-op, as DpkgArch requires no resolution.
+func (a *DpkgArch) Resolve(ctx context.Context, host types.Host) error {
+	return nil
+}
+
 func (a *DpkgArch) Apply(ctx context.Context, host types.Host) error {
 	systemArch, err := getSystemArch(ctx, host)
 	if err != nil {
@@ -162,3 +201,10 @@ func (a *DpkgArch) Apply(ctx context.Context, host types.Host) error {
 
 	return nil
 }
+
+func init() {
+	RegisterResourceType(&ResourceType{
+		Name: "DpkgArch",
+		New:  func(id string) Resource { return &DpkgArch{} },
+	})
+}
@@ -56,6 +56,11 @@ func TestDpkgArchIntegration(t *testing.T) {
 				dpkgArch := &DpkgArch{ForeignArchitectures: []string{foreignArch}}
 				require.NoError(t, dpkgArch.Apply(ctx, host))
 
+				// Load
+				loadedDpkgArch := &DpkgArch{}
+				require.NoError(t, loadedDpkgArch.Load(ctx, host))
+				require.Equal(t, dpkgArch, loadedDpkgArch)
+
 				// Check that the foreign architecture was added
 				foreignOut := strings.TrimSpace(runAndRequireSuccess(t, ctx, host, types.Cmd{
 					Path: "/usr/bin/dpkg", Args: []string{"--print-foreign-architectures"},
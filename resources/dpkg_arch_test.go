@@ -10,9 +10,10 @@ func TestDpkgArch(t *testing.T) {
 	t.Run("Satisfies()", func(t *testing.T) {
 		a := &DpkgArch{ForeignArchitectures: []string{"i386", "arm64"}}
 		b := &DpkgArch{ForeignArchitectures: []string{"i386"}}
-		require.True(t, a.Satisfies(b))
+		require.False(t, a.Satisfies(b))
 		require.False(t, b.Satisfies(a))
-		require.True(t, a.Satisfies(&DpkgArch{ForeignArchitectures: []string{}}))
+		require.True(t, a.Satisfies(&DpkgArch{ForeignArchitectures: []string{"arm64", "i386"}}))
+		require.False(t, a.Satisfies(&DpkgArch{ForeignArchitectures: []string{}}))
 		require.True(t, (&DpkgArch{ForeignArchitectures: []string{}}).Satisfies(&DpkgArch{ForeignArchitectures: []string{}}))
 		require.False(t, (&DpkgArch{ForeignArchitectures: []string{}}).Satisfies(a))
 	})
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,33 +19,85 @@ import (
 // File manages files
 type File struct {
 	// Path is the absolute path to the file
-	Path string
+	Path string `yaml:"path"`
 	// Whether to remove the file
-	Absent bool
+	Absent bool `yaml:"absent,omitempty"`
 	// Create a socket file
-	Socket bool
+	Socket bool `yaml:"socket,omitempty"`
 	// Create a symbolic link pointing to given path
-	SymbolicLink string
+	SymbolicLink string `yaml:"symbolic_link,omitempty"`
 	// Create a regular file with given contents
-	RegularFile *string
+	RegularFile *string `yaml:"regular_file,omitempty"`
 	// Create a block device file with given majon / minor.
-	BlockDevice *types.FileDevice
+	BlockDevice *types.FileDevice `yaml:"block_device,omitempty"`
 	// Create a directory with given contents
-	Directory *[]File
+	Directory *[]File `yaml:"directory,omitempty"`
 	// Create a character device file with given majon / minor
-	CharacterDevice *types.FileDevice
+	CharacterDevice *types.FileDevice `yaml:"character_device,omitempty"`
 	// Create a FIFO file
-	FIFO bool
+	FIFO bool `yaml:"fifo,omitempty"`
 	// Mode bits 07777, see inode(7).
-	Mode *types.FileMode
+	Mode *types.FileMode `yaml:"mode,omitempty"`
 	// User ID owner of the file. Default: 0.
-	Uid *uint32
+	Uid *uint32 `yaml:"uid,omitempty"`
 	// User name owner of the file
-	User *string
+	User *string `yaml:"user,omitempty"`
 	// Group ID owner of the file. Default: 0.
-	Gid *uint32
+	Gid *uint32 `yaml:"gid,omitempty"`
 	// Group name owner of the file
-	Group *string
+	Group *string `yaml:"group,omitempty"`
+}
+
+// ID returns the file path.
+func (f *File) ID() string {
+	return f.Path
+}
+
+// Satisfies returns true only when a satisfies b. Both a and b must be resolved, and mode is only
+// checked when set at b.
+func (a *File) Satisfies(resource Resource) bool {
+	b, ok := resource.(*File)
+	if !ok {
+		return false
+	}
+
+	if a.Path != b.Path ||
+		a.Absent != b.Absent ||
+		a.Socket != b.Socket ||
+		a.SymbolicLink != b.SymbolicLink ||
+		a.FIFO != b.FIFO {
+		return false
+	}
+
+	if !reflect.DeepEqual(a.RegularFile, b.RegularFile) ||
+		!reflect.DeepEqual(a.BlockDevice, b.BlockDevice) ||
+		!reflect.DeepEqual(a.CharacterDevice, b.CharacterDevice) {
+		return false
+	}
+
+	if b.Mode != nil && !reflect.DeepEqual(a.Mode, b.Mode) {
+		return false
+	}
+
+	if !reflect.DeepEqual(a.Uid, b.Uid) || !reflect.DeepEqual(a.Gid, b.Gid) {
+		return false
+	}
+
+	if (a.Directory == nil) != (b.Directory == nil) {
+		return false
+	}
+	if b.Directory != nil {
+		if len(*a.Directory) != len(*b.Directory) {
+			return false
+		}
+		for i := range *b.Directory {
+			if !(&(*a.Directory)[i]).Satisfies(&(*b.Directory)[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
 }
 
 func (f *File) validatePath() error {
@@ -357,13 +410,25 @@ func (f *File) removeRecursively(ctx context.Context, host types.Host) error {
 	return host.Remove(ctx, f.Path)
 }
 
+// modeOrDefault returns the mode to use when creating the file: the defined mode, or a default
+// one when unset.
+func (f *File) modeOrDefault() types.FileMode {
+	if f.Mode != nil {
+		return *f.Mode
+	}
+	if f.Directory != nil {
+		return 0755
+	}
+	return 0644
+}
+
 func (f *File) applySocket(ctx context.Context, host types.Host, currentFile *File) error {
 	if f.Socket {
 		if !currentFile.Socket {
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.Mknod(ctx, f.Path, *f.Mode|syscall.S_IFSOCK, 0); err != nil {
+			if err := host.Mknod(ctx, f.Path, f.modeOrDefault()|syscall.S_IFSOCK, 0); err != nil {
 				return err
 			}
 		}
@@ -391,7 +456,7 @@ func (f *File) applyRegularFile(ctx context.Context, host types.Host, currentFil
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.WriteFile(ctx, string(f.Path), strings.NewReader(*f.RegularFile), *f.Mode); err != nil {
+			if err := host.WriteFile(ctx, string(f.Path), strings.NewReader(*f.RegularFile), f.modeOrDefault()); err != nil {
 				return err
 			}
 		}
@@ -405,7 +470,7 @@ func (f *File) applyBlockDevice(ctx context.Context, host types.Host, currentFil
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.Mknod(ctx, f.Path, *f.Mode|syscall.S_IFBLK, *f.BlockDevice); err != nil {
+			if err := host.Mknod(ctx, f.Path, f.modeOrDefault()|syscall.S_IFBLK, *f.BlockDevice); err != nil {
 				return err
 			}
 		}
@@ -419,7 +484,7 @@ func (f *File) applyDirectory(ctx context.Context, host types.Host, currentFile
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.Mkdir(ctx, f.Path, *f.Mode); err != nil {
+			if err := host.Mkdir(ctx, f.Path, f.modeOrDefault()); err != nil {
 				return err
 			}
 		}
@@ -454,7 +519,7 @@ func (f *File) applyCharacterDevice(ctx context.Context, host types.Host, curren
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.Mknod(ctx, f.Path, *f.Mode|syscall.S_IFCHR, *f.CharacterDevice); err != nil {
+			if err := host.Mknod(ctx, f.Path, f.modeOrDefault()|syscall.S_IFCHR, *f.CharacterDevice); err != nil {
 				return err
 			}
 		}
@@ -468,7 +533,7 @@ func (f *File) applyFIFO(ctx context.Context, host types.Host, currentFile *File
 			if err := currentFile.removeRecursively(ctx, host); err != nil {
 				return err
 			}
-			if err := host.Mknod(ctx, f.Path, *f.Mode|syscall.S_IFIFO, 0); err != nil {
+			if err := host.Mknod(ctx, f.Path, f.modeOrDefault()|syscall.S_IFIFO, 0); err != nil {
 				return err
 			}
 		}
@@ -528,3 +593,54 @@ func (f *File) Apply(ctx context.Context, host types.Host) error {
 
 	return nil
 }
+
+func (f *File) getRefreshesFromRules() []Refresh {
+	refreshes := getFileRefreshesFromRules(f.Path, f.Absent)
+	if f.Directory != nil {
+		for _, subFile := range *f.Directory {
+			refreshes = append(refreshes, subFile.getRefreshesFromRules()...)
+		}
+	}
+	return refreshes
+}
+
+// Refreshes returns the refreshes required when the file changes: systemd units and their
+// configuration are refreshed as declared at fileRefreshRules, while changes under /etc reload
+// all services shipped by the package which owns the path (or its closest parent directory).
+func (f *File) Refreshes(ctx context.Context, host types.Host, current Resource) ([]Refresh, error) {
+	refreshes := f.getRefreshesFromRules()
+	if len(refreshes) > 0 {
+		return refreshes, nil
+	}
+
+	ok, err := hasDpkg(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return refreshes, nil
+	}
+
+	packages, err := getDpkgPathPackages(ctx, host, f.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		services, err := getDpkgPackageServices(ctx, host, pkg)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			refreshes = append(refreshes, Refresh{Action: RefreshActionReloadOrRestart, Unit: service})
+		}
+	}
+
+	return refreshes, nil
+}
+
+func init() {
+	RegisterResourceType(&ResourceType{
+		Name: "File",
+		New:  func(id string) Resource { return &File{Path: id} },
+	})
+}
@@ -303,6 +303,60 @@ func TestFile(t *testing.T) {
 		}
 	})
 
+	t.Run("Satisfies()", func(t *testing.T) {
+		otherContents := "other"
+		var otherMode types.FileMode = 0600
+		regularFile := File{
+			Path:        "/foo",
+			RegularFile: &contents,
+			Mode:        &mode,
+			Uid:         &uid,
+			Gid:         &gid,
+		}
+		require.True(t, regularFile.Satisfies(&regularFile))
+		require.True(t, regularFile.Satisfies(&File{
+			Path:        "/foo",
+			RegularFile: &contents,
+			Uid:         &uid,
+			Gid:         &gid,
+		}))
+		require.False(t, regularFile.Satisfies(&File{
+			Path:        "/foo",
+			RegularFile: &contents,
+			Mode:        &otherMode,
+			Uid:         &uid,
+			Gid:         &gid,
+		}))
+		require.False(t, regularFile.Satisfies(&File{
+			Path:        "/foo",
+			RegularFile: &otherContents,
+			Mode:        &mode,
+			Uid:         &uid,
+			Gid:         &gid,
+		}))
+		require.False(t, regularFile.Satisfies(&File{
+			Path:   "/foo",
+			Absent: true,
+		}))
+
+		regularFile.Path = "/foo/bar"
+		directory := File{
+			Path:      "/foo",
+			Directory: &[]File{regularFile},
+			Mode:      &mode,
+			Uid:       &uid,
+			Gid:       &gid,
+		}
+		require.True(t, directory.Satisfies(&directory))
+		require.False(t, directory.Satisfies(&File{
+			Path:      "/foo",
+			Directory: &[]File{},
+			Mode:      &mode,
+			Uid:       &uid,
+			Gid:       &gid,
+		}))
+	})
+
 	t.Run("Load()", func(t *testing.T) {
 		t.Run("existing", func(t *testing.T) {
 			prefix := t.TempDir()
@@ -0,0 +1,270 @@
+package resources
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host/lib"
+	"github.com/fornellas/resonance/host/types"
+)
+
+// RefreshAction is a systemctl(1) command used to refresh systemd units.
+type RefreshAction string
+
+const (
+	// RefreshActionDaemonReload reloads the systemd manager configuration. It is not specific to
+	// any unit.
+	RefreshActionDaemonReload RefreshAction = "daemon-reload"
+	// RefreshActionReloadOrRestart reloads the unit if it supports it, otherwise restarts it. Units
+	// which are not running are left untouched.
+	RefreshActionReloadOrRestart RefreshAction = "try-reload-or-restart"
+	// RefreshActionRestart restarts the unit. Units which are not running are left untouched.
+	RefreshActionRestart RefreshAction = "try-restart"
+)
+
+// Refresh is an action required to refresh a systemd unit after its inputs changed.
+type Refresh struct {
+	Action RefreshAction
+	// Unit name, eg: nginx.service. Empty for RefreshActionDaemonReload.
+	Unit string
+}
+
+// RefreshTrigger is optionally implemented by resources which, when changed, require systemd units
+// to be refreshed.
+type RefreshTrigger interface {
+	Resource
+	// Refreshes returns all refreshes required after the resource was changed from current, its
+	// state before it was applied.
+	Refreshes(ctx context.Context, host types.Host, current Resource) ([]Refresh, error)
+}
+
+// fileRefreshRule declares the refreshes required when a File matching Regexp changes.
+type fileRefreshRule struct {
+	Regexp *regexp.Regexp
+	// Unit name template, expanded with the Regexp submatches, as in regexp.Regexp.Expand.
+	Unit string
+	// Actions to take, in order.
+	Actions []RefreshAction
+	// AbsentActions to take instead of Actions, when the file was removed.
+	AbsentActions []RefreshAction
+}
+
+var systemdUnitPathsRegexpStr = `/(?:etc|run|lib|usr/lib)/systemd/system`
+
+var systemdUnitRegexpStr = `[^/]+\.(?:service|socket|timer|path|mount|automount|swap|target)`
+
+// fileRefreshRules maps changes to File paths to the refreshes they require.
+var fileRefreshRules = []fileRefreshRule{
+	// Unit files
+	{
+		Regexp:        regexp.MustCompile(`^` + systemdUnitPathsRegexpStr + `/(` + systemdUnitRegexpStr + `)$`),
+		Unit:          "$1",
+		Actions:       []RefreshAction{RefreshActionDaemonReload, RefreshActionRestart},
+		AbsentActions: []RefreshAction{RefreshActionDaemonReload},
+	},
+	// Unit drop-ins
+	{
+		Regexp:        regexp.MustCompile(`^` + systemdUnitPathsRegexpStr + `/(` + systemdUnitRegexpStr + `)\.d/[^/]+\.conf$`),
+		Unit:          "$1",
+		Actions:       []RefreshAction{RefreshActionDaemonReload, RefreshActionRestart},
+		AbsentActions: []RefreshAction{RefreshActionDaemonReload, RefreshActionRestart},
+	},
+	// systemd daemons configuration, eg: /etc/systemd/journald.conf
+	{
+		Regexp:        regexp.MustCompile(`^/etc/systemd/(journald|logind|networkd|resolved|timesyncd)\.conf(?:\.d/[^/]+\.conf)?$`),
+		Unit:          "systemd-$1.service",
+		Actions:       []RefreshAction{RefreshActionRestart},
+		AbsentActions: []RefreshAction{RefreshActionRestart},
+	},
+}
+
+// getFileRefreshesFromRules returns the refreshes from fileRefreshRules for a change at path.
+func getFileRefreshesFromRules(path string, absent bool) []Refresh {
+	refreshes := []Refresh{}
+	for _, rule := range fileRefreshRules {
+		submatches := rule.Regexp.FindStringSubmatchIndex(path)
+		if submatches == nil {
+			continue
+		}
+		unit := string(rule.Regexp.ExpandString(nil, rule.Unit, path, submatches))
+		actions := rule.Actions
+		if absent {
+			actions = rule.AbsentActions
+		}
+		for _, action := range actions {
+			refresh := Refresh{Action: action}
+			if action != RefreshActionDaemonReload {
+				refresh.Unit = unit
+			}
+			refreshes = append(refreshes, refresh)
+		}
+	}
+	return refreshes
+}
+
+// hasDpkg returns whether host has dpkg, which is used to find out which units are shipped by
+// packages.
+func hasDpkg(ctx context.Context, host types.Host) (bool, error) {
+	if _, err := host.Lstat(ctx, "/usr/bin/dpkg-query"); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// getDpkgPathPackages returns the packages which own given path, or, when none, its closest parent
+// directory under /etc.
+func getDpkgPathPackages(ctx context.Context, host types.Host, path string) ([]string, error) {
+	for ; strings.HasPrefix(path, "/etc/"); path = filepath.Dir(path) {
+		cmd := types.Cmd{
+			Path: "/usr/bin/dpkg-query",
+			Args: []string{"--search", path},
+		}
+		waitStatus, stdout, stderr, err := lib.Run(ctx, host, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", cmd, err)
+		}
+		if !waitStatus.Success() {
+			if waitStatus.Exited && waitStatus.ExitCode == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s failed: %s\nSTDOUT:\n%s\nSTDERR:\n%s", cmd, waitStatus.String(), stdout, stderr)
+		}
+
+		packages := []string{}
+		scanner := bufio.NewScanner(strings.NewReader(stdout))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "diversion by ") {
+				continue
+			}
+			packagesStr, _, found := strings.Cut(line, ": ")
+			if !found {
+				continue
+			}
+			for _, pkg := range strings.Split(packagesStr, ", ") {
+				pkg, _, _ = strings.Cut(pkg, ":")
+				if !slices.Contains(packages, pkg) {
+					packages = append(packages, pkg)
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return packages, nil
+	}
+	return nil, nil
+}
+
+var dpkgPackageServiceRegexp = regexp.MustCompile(`^/(?:usr/)?lib/systemd/system/([^/@]+\.service)$`)
+
+// getDpkgPackageServices returns all service units shipped by given package.
+func getDpkgPackageServices(ctx context.Context, host types.Host, pkg string) ([]string, error) {
+	cmd := types.Cmd{
+		Path: "/usr/bin/dpkg-query",
+		Args: []string{"--listfiles", pkg},
+	}
+	waitStatus, stdout, stderr, err := lib.Run(ctx, host, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", cmd, err)
+	}
+	if !waitStatus.Success() {
+		return nil, fmt.Errorf("%s failed: %s\nSTDOUT:\n%s\nSTDERR:\n%s", cmd, waitStatus.String(), stdout, stderr)
+	}
+
+	services := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		submatches := dpkgPackageServiceRegexp.FindStringSubmatch(scanner.Text())
+		if submatches == nil {
+			continue
+		}
+		if !slices.Contains(services, submatches[1]) {
+			services = append(services, submatches[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// MergeRefreshes removes duplicated refreshes, keeping the order they first appear, but with
+// RefreshActionDaemonReload first. When a unit has both RefreshActionReloadOrRestart and
+// RefreshActionRestart, only RefreshActionRestart is kept.
+func MergeRefreshes(refreshes []Refresh) []Refresh {
+	merged := []Refresh{}
+	unitIdx := map[string]int{}
+	for _, refresh := range refreshes {
+		if refresh.Action == RefreshActionDaemonReload {
+			if len(merged) == 0 || merged[0].Action != RefreshActionDaemonReload {
+				merged = append([]Refresh{refresh}, merged...)
+				for unit := range unitIdx {
+					unitIdx[unit]++
+				}
+			}
+			continue
+		}
+		if i, ok := unitIdx[refresh.Unit]; ok {
+			if refresh.Action == RefreshActionRestart {
+				merged[i].Action = RefreshActionRestart
+			}
+			continue
+		}
+		unitIdx[refresh.Unit] = len(merged)
+		merged = append(merged, refresh)
+	}
+	return merged
+}
+
+// ApplyRefreshes merges all given refreshes with MergeRefreshes, and runs them at host. If host is
+// not running systemd, refreshes are skipped.
+func ApplyRefreshes(ctx context.Context, host types.Host, refreshes []Refresh) error {
+	refreshes = MergeRefreshes(refreshes)
+	if len(refreshes) == 0 {
+		return nil
+	}
+
+	ctx, logger := log.MustWithGroup(ctx, "🔄 Refresh")
+
+	if _, err := host.Lstat(ctx, "/run/systemd/system"); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Warn("Host is not running systemd, skipping")
+			return nil
+		}
+		return err
+	}
+
+	for _, refresh := range refreshes {
+		args := []string{string(refresh.Action)}
+		if refresh.Unit != "" {
+			args = append(args, refresh.Unit)
+		}
+		logger.Info("Refreshing", "action", refresh.Action, "unit", refresh.Unit)
+		cmd := types.Cmd{
+			Path: "systemctl",
+			Args: args,
+		}
+		waitStatus, stdout, stderr, err := lib.Run(ctx, host, cmd)
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w", cmd, err)
+		}
+		if !waitStatus.Success() {
+			return fmt.Errorf("%s failed: %s\nSTDOUT:\n%s\nSTDERR:\n%s", cmd, waitStatus.String(), stdout, stderr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFileRefreshesFromRules(t *testing.T) {
+	type testCase struct {
+		name              string
+		path              string
+		absent            bool
+		expectedRefreshes []Refresh
+	}
+	for _, tc := range []testCase{
+		{
+			name: "unit file",
+			path: "/etc/systemd/system/foo.service",
+			expectedRefreshes: []Refresh{
+				{Action: RefreshActionDaemonReload},
+				{Action: RefreshActionRestart, Unit: "foo.service"},
+			},
+		},
+		{
+			name:   "absent unit file",
+			path:   "/lib/systemd/system/foo.timer",
+			absent: true,
+			expectedRefreshes: []Refresh{
+				{Action: RefreshActionDaemonReload},
+			},
+		},
+		{
+			name:   "unit drop-in",
+			path:   "/etc/systemd/system/foo.socket.d/override.conf",
+			absent: true,
+			expectedRefreshes: []Refresh{
+				{Action: RefreshActionDaemonReload},
+				{Action: RefreshActionRestart, Unit: "foo.socket"},
+			},
+		},
+		{
+			name: "systemd daemon configuration",
+			path: "/etc/systemd/journald.conf",
+			expectedRefreshes: []Refresh{
+				{Action: RefreshActionRestart, Unit: "systemd-journald.service"},
+			},
+		},
+		{
+			name: "systemd daemon configuration drop-in",
+			path: "/etc/systemd/resolved.conf.d/dns.conf",
+			expectedRefreshes: []Refresh{
+				{Action: RefreshActionRestart, Unit: "systemd-resolved.service"},
+			},
+		},
+		{
+			name:              "no match",
+			path:              "/etc/foo.conf",
+			expectedRefreshes: []Refresh{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedRefreshes, getFileRefreshesFromRules(tc.path, tc.absent))
+		})
+	}
+}
+
+func TestMergeRefreshes(t *testing.T) {
+	require.Equal(t,
+		[]Refresh{
+			{Action: RefreshActionDaemonReload},
+			{Action: RefreshActionRestart, Unit: "foo.service"},
+			{Action: RefreshActionReloadOrRestart, Unit: "bar.service"},
+			{Action: RefreshActionRestart, Unit: "baz.service"},
+		},
+		MergeRefreshes([]Refresh{
+			{Action: RefreshActionReloadOrRestart, Unit: "foo.service"},
+			{Action: RefreshActionReloadOrRestart, Unit: "bar.service"},
+			{Action: RefreshActionDaemonReload},
+			{Action: RefreshActionRestart, Unit: "foo.service"},
+			{Action: RefreshActionRestart, Unit: "baz.service"},
+			{Action: RefreshActionDaemonReload},
+			{Action: RefreshActionReloadOrRestart, Unit: "bar.service"},
+		}),
+	)
+	require.Equal(t, []Refresh{}, MergeRefreshes(nil))
+}
@@ -0,0 +1,95 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fornellas/resonance/concurrency"
+	"github.com/fornellas/resonance/host/types"
+)
+
+// ResourceType describes a resource type that can be declared at a blueprint.
+type ResourceType struct {
+	// Name of the resource type, as used at blueprints, eg: File.
+	Name string
+	// New returns a new resource with only given id set.
+	New func(id string) Resource
+}
+
+// Load the current state of resources with given ids from host.
+func (r *ResourceType) Load(ctx context.Context, host types.Host, ids []string) ([]Resource, error) {
+	resources := make([]Resource, len(ids))
+	sources := make([]string, len(ids))
+	for i, id := range ids {
+		resources[i] = r.New(id)
+		sources[i] = fmt.Sprintf("%s: %s", id, r.Name)
+	}
+	if err := LoadResources(ctx, host, resources, sources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// LoadResources loads the current state of all given resources from host. GroupResource are
+// loaded together with all other resources of the same ResourceGroup. sources holds, for each
+// resource, where it comes from (eg: "blueprint.yaml:3: File"), which prefixes errors loading it.
+func LoadResources(ctx context.Context, host types.Host, resources []Resource, sources []string) error {
+	concurrencyGroup := concurrency.NewConcurrencyGroup(ctx)
+	groupResourcesMap := map[ResourceGroup][]GroupResource{}
+	groupSourcesMap := map[ResourceGroup][]string{}
+	for i, resource := range resources {
+		switch resource := resource.(type) {
+		case SingleResource:
+			concurrencyGroup.Run(func() error {
+				if err := resource.Load(ctx, host); err != nil {
+					return fmt.Errorf("%s: %w", sources[i], err)
+				}
+				return nil
+			})
+		case GroupResource:
+			groupResourcesMap[resource.Group()] = append(groupResourcesMap[resource.Group()], resource)
+			groupSourcesMap[resource.Group()] = append(groupSourcesMap[resource.Group()], sources[i])
+		default:
+			panic(fmt.Sprintf("bug: %T is neither a SingleResource nor a GroupResource", resource))
+		}
+	}
+	for resourceGroup, groupResources := range groupResourcesMap {
+		concurrencyGroup.Run(func() error {
+			if err := resourceGroup.Load(ctx, host, groupResources); err != nil {
+				return fmt.Errorf("%s: %w", strings.Join(groupSourcesMap[resourceGroup], ", "), err)
+			}
+			return nil
+		})
+	}
+	return errors.Join(concurrencyGroup.Wait()...)
+}
+
+var resourceTypeMap = map[string]*ResourceType{}
+
+// RegisterResourceType registers a new resource type, making it available to blueprints. It is
+// meant to be called from init functions, and panics if a resource type with the same name was
+// already registered.
+func RegisterResourceType(resourceType *ResourceType) {
+	if _, ok := resourceTypeMap[resourceType.Name]; ok {
+		panic(fmt.Sprintf("bug: resource type %#v already registered", resourceType.Name))
+	}
+	resourceTypeMap[resourceType.Name] = resourceType
+}
+
+// GetResourceType returns the registered resource type with given name, or nil if none.
+func GetResourceType(name string) *ResourceType {
+	return resourceTypeMap[name]
+}
+
+// ResourceTypeNames returns the sorted names of all registered resource types.
+func ResourceTypeNames() []string {
+	names := []string{}
+	for name := range resourceTypeMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
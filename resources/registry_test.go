@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+var _ SingleResource = &File{}
+var _ SingleResource = &DpkgArch{}
+var _ GroupResource = &APTPackage{}
+var _ ResourceGroup = &APTPackages{}
+var _ RefreshTrigger = &File{}
+var _ RefreshTrigger = &APTPackage{}
+
+func TestRegistry(t *testing.T) {
+	require.Equal(t, []string{"APTPackage", "DpkgArch", "File"}, ResourceTypeNames())
+
+	resourceType := GetResourceType("File")
+	require.NotNil(t, resourceType)
+	require.Equal(t, "File", resourceType.Name)
+	require.Equal(t, &File{Path: "/foo"}, resourceType.New("/foo"))
+
+	require.Nil(t, GetResourceType("Foo"))
+
+	require.PanicsWithValue(t, `bug: resource type "File" already registered`, func() {
+		RegisterResourceType(&ResourceType{Name: "File"})
+	})
+}
+
+type failingResource struct{}
+
+func (r *failingResource) ID() string              { return "failing" }
+func (r *failingResource) Validate() error         { return nil }
+func (r *failingResource) Satisfies(Resource) bool { return false }
+func (r *failingResource) Load(ctx context.Context, host types.Host) error {
+	return errors.New("load failed")
+}
+func (r *failingResource) Resolve(ctx context.Context, host types.Host) error { return nil }
+func (r *failingResource) Apply(ctx context.Context, host types.Host) error   { return nil }
+
+func TestLoadResources(t *testing.T) {
+	err := LoadResources(
+		t.Context(), nil,
+		[]Resource{&failingResource{}, &failingResource{}},
+		[]string{"foo.yaml:1: Failing", "foo.yaml:5: Failing"},
+	)
+	require.ErrorContains(t, err, "foo.yaml:1: Failing: load failed")
+	require.ErrorContains(t, err, "foo.yaml:5: Failing: load failed")
+}
@@ -0,0 +1,52 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/fornellas/resonance/host/types"
+)
+
+// Resource is implemented by all resource types that can be declared at a blueprint. Each resource
+// must also implement either SingleResource or GroupResource.
+type Resource interface {
+	// ID uniquely identifies the resource among all resources of the same type, eg: File path.
+	ID() string
+	// Validate whether the resource definition is valid.
+	Validate() error
+	// Satisfies returns true only when the resource satisfies the given resource of the same type.
+	// Both must be resolved.
+	Satisfies(Resource) bool
+}
+
+// SingleResource is a Resource which is managed independently of other resources.
+type SingleResource interface {
+	Resource
+	// Load the current state from host. Only the ID is required to be set, and all other fields
+	// are overwritten.
+	Load(ctx context.Context, host types.Host) error
+	// Resolve any information from host required for the resource to be compared with its loaded
+	// state, eg: user names to uids.
+	Resolve(ctx context.Context, host types.Host) error
+	// Apply the resource to host.
+	Apply(ctx context.Context, host types.Host) error
+}
+
+// GroupResource is a Resource which must be managed together with all other resources of the same
+// type via its ResourceGroup, eg: APTPackage.
+type GroupResource interface {
+	Resource
+	// Group returns the ResourceGroup which manages resources of this type.
+	Group() ResourceGroup
+}
+
+// ResourceGroup manages a group of GroupResource of the same type at once.
+type ResourceGroup interface {
+	// Load the current state of all resources from host. Only the ID of each resource is required
+	// to be set, and all other fields are overwritten.
+	Load(ctx context.Context, host types.Host, resources []GroupResource) error
+	// Resolve any information from host required for resources to be compared with their loaded
+	// state.
+	Resolve(ctx context.Context, host types.Host, resources []GroupResource) error
+	// Apply all resources to host at once.
+	Apply(ctx context.Context, host types.Host, resources []GroupResource) error
+}
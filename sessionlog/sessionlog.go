@@ -0,0 +1,297 @@
+// Structured session logs, in JSON lines format, which can be replayed as originally logged.
+package sessionlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// Attr is a slog.Attr at a Line.
+type Attr struct {
+	Key string `json:"key"`
+	// Value of the attribute, for non group attributes. Strings, numbers and booleans are kept as
+	// is, and all other values are kept as their string representation.
+	Value any `json:"value,omitempty"`
+	// TerminalValue is the value with ANSI escape sequences, for values implementing
+	// log.TerminalValuer.
+	TerminalValue string `json:"terminal_value,omitempty"`
+	// Group holds the attributes of group attributes.
+	Group []Attr `json:"group,omitempty"`
+}
+
+// Group is a group at a Line, along with the attributes added to it.
+type Group struct {
+	// ID identifies the handler which added the group, or attributes to it: lines sharing a group
+	// ID were logged with the same handler.
+	ID uint64 `json:"id"`
+	// Name of the group, which is empty for the root group.
+	Name  string `json:"name,omitempty"`
+	Attrs []Attr `json:"attrs,omitempty"`
+}
+
+// Source is the source code position of the log statement of a Line.
+type Source struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Line is a log record at a session log.
+type Line struct {
+	Time  time.Time  `json:"time"`
+	Level slog.Level `json:"level"`
+	// Groups holds the group path of the record, starting with the root group.
+	Groups  []Group `json:"groups"`
+	Message string  `json:"msg"`
+	Attrs   []Attr  `json:"attrs,omitempty"`
+	Source  *Source `json:"source,omitempty"`
+}
+
+// GroupPath returns the names of all groups of the line, excluding the root group.
+func (l *Line) GroupPath() []string {
+	groupPath := []string{}
+	for _, group := range l.Groups {
+		if group.Name != "" {
+			groupPath = append(groupPath, group.Name)
+		}
+	}
+	return groupPath
+}
+
+func newAttr(attr slog.Attr) (Attr, bool) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return Attr{}, false
+	}
+	a := Attr{Key: attr.Key}
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		a.Group = newAttrs(attr.Value.Group())
+		if len(a.Group) == 0 {
+			return Attr{}, false
+		}
+	case slog.KindString:
+		a.Value = attr.Value.String()
+	case slog.KindInt64:
+		a.Value = attr.Value.Int64()
+	case slog.KindUint64:
+		a.Value = attr.Value.Uint64()
+	case slog.KindFloat64:
+		if f := attr.Value.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			a.Value = f
+		} else {
+			a.Value = attr.Value.String()
+		}
+	case slog.KindBool:
+		a.Value = attr.Value.Bool()
+	default:
+		a.Value = attr.Value.String()
+		if terminalValuer, ok := attr.Value.Any().(log.TerminalValuer); ok {
+			a.TerminalValue = terminalValuer.TerminalValue().String()
+		}
+	}
+	return a, true
+}
+
+func newAttrs(attrs []slog.Attr) []Attr {
+	as := []Attr{}
+	for _, attr := range attrs {
+		if a, ok := newAttr(attr); ok {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// slogAttr returns the slog.Attr for a, as originally logged.
+func (a Attr) slogAttr() slog.Attr {
+	if a.Group != nil {
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(slogAttrs(a.Group)...)}
+	}
+	if a.TerminalValue != "" {
+		return slog.Any(a.Key, log.NewTerminalValue(a.TerminalValue))
+	}
+	switch value := a.Value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(value.String(), 10, 64); err == nil {
+			return slog.Int64(a.Key, i)
+		}
+		if u, err := strconv.ParseUint(value.String(), 10, 64); err == nil {
+			return slog.Uint64(a.Key, u)
+		}
+		if f, err := value.Float64(); err == nil {
+			return slog.Float64(a.Key, f)
+		}
+		return slog.String(a.Key, value.String())
+	default:
+		return slog.Any(a.Key, value)
+	}
+}
+
+func slogAttrs(as []Attr) []slog.Attr {
+	attrs := make([]slog.Attr, len(as))
+	for i, a := range as {
+		attrs[i] = a.slogAttr()
+	}
+	return attrs
+}
+
+// Handler is a slog.Handler which writes a session log, with a Line per record, in JSON lines
+// format.
+type Handler struct {
+	opts   slog.HandlerOptions
+	writer io.Writer
+	mutex  *sync.Mutex
+	lastID *atomic.Uint64
+	groups []Group
+}
+
+// NewHandler creates a new Handler writing to w.
+func NewHandler(w io.Writer, opts *slog.HandlerOptions) *Handler {
+	h := &Handler{
+		writer: w,
+		mutex:  &sync.Mutex{},
+		lastID: &atomic.Uint64{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	h.groups = []Group{{ID: h.lastID.Add(1)}}
+	return h
+}
+
+// Enabled implements slog.Handler.Enabled
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// WithAttrs implements slog.Handler.WithAttrs
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.groups = slices.Clone(h.groups)
+	group := &h2.groups[len(h2.groups)-1]
+	group.ID = h.lastID.Add(1)
+	group.Attrs = append(slices.Clone(group.Attrs), newAttrs(attrs)...)
+	return &h2
+}
+
+// WithGroup implements slog.Handler.WithGroup
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clone(h.groups), Group{ID: h.lastID.Add(1), Name: name})
+	return &h2
+}
+
+// Handle implements slog.Handler.Handle
+func (h *Handler) Handle(_ context.Context, record slog.Record) error {
+	line := Line{
+		Time:    record.Time,
+		Level:   record.Level,
+		Groups:  h.groups,
+		Message: record.Message,
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		if a, ok := newAttr(attr); ok {
+			line.Attrs = append(line.Attrs, a)
+		}
+		return true
+	})
+	if h.opts.AddSource && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		line.Source = &Source{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(line); err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err := h.writer.Write(buffer.Bytes())
+	return err
+}
+
+// Replay reads a session log written by Handler from reader, and handles each of its records with
+// handler, as they were originally logged, so that it renders the same output. Source code
+// positions can not be replayed.
+func Replay(ctx context.Context, reader io.Reader, handler slog.Handler) error {
+	bufReader := bufio.NewReader(reader)
+	handlers := map[string]slog.Handler{}
+	for number := 1; ; number++ {
+		lineBytes, err := bufReader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(strings.TrimSpace(string(lineBytes))) > 0 {
+			decoder := json.NewDecoder(strings.NewReader(string(lineBytes)))
+			decoder.UseNumber()
+			var line Line
+			if err := decoder.Decode(&line); err != nil {
+				return fmt.Errorf("line %d: not a session log line: %w", number, err)
+			}
+			if err := replayLine(ctx, handlers, handler, &line); err != nil {
+				return fmt.Errorf("line %d: %w", number, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// replayLine handles line with handler, reusing handlers from previous lines for groups with
+// the same ID.
+func replayLine(ctx context.Context, handlers map[string]slog.Handler, handler slog.Handler, line *Line) error {
+	var key strings.Builder
+	for i, group := range line.Groups {
+		fmt.Fprintf(&key, "/%d", group.ID)
+		if cachedHandler, ok := handlers[key.String()]; ok {
+			handler = cachedHandler
+			continue
+		}
+		if i > 0 {
+			handler = handler.WithGroup(group.Name)
+		}
+		if len(group.Attrs) > 0 {
+			handler = handler.WithAttrs(slogAttrs(group.Attrs))
+		}
+		handlers[key.String()] = handler
+	}
+
+	if !handler.Enabled(ctx, line.Level) {
+		return nil
+	}
+	record := slog.NewRecord(line.Time, line.Level, line.Message, 0)
+	record.AddAttrs(slogAttrs(line.Attrs)...)
+	return handler.Handle(ctx, record)
+}
@@ -0,0 +1,104 @@
+package sessionlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+func newTestTreeHandler(w *bytes.Buffer, level slog.Level) slog.Handler {
+	return log.NewTerminalTreeHandler(w, &log.TerminalHandlerOptions{
+		HandlerOptions: slog.HandlerOptions{
+			Level: level,
+		},
+		TimeLayout: time.RFC3339Nano,
+		ForceColor: true,
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var buffer bytes.Buffer
+	handler := NewHandler(&buffer, &slog.HandlerOptions{AddSource: true})
+	logger := slog.New(handler).With("root", "value").WithGroup("👀 Group").With("n", 1)
+
+	logger.Debug("debug")
+	logger.Info("info", "float", 1.5, "nan", math.NaN(), slog.Group("group", "bool", true))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, lines, 1)
+	var line Line
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+
+	require.Equal(t, slog.LevelInfo, line.Level)
+	require.Equal(t, "info", line.Message)
+	require.Equal(t, []string{"👀 Group"}, line.GroupPath())
+	require.Len(t, line.Groups, 2)
+	require.Equal(t, []Attr{{Key: "root", Value: "value"}}, line.Groups[0].Attrs)
+	require.Equal(t, []Attr{{Key: "n", Value: 1.0}}, line.Groups[1].Attrs)
+	require.Equal(t, []Attr{
+		{Key: "float", Value: 1.5},
+		{Key: "nan", Value: "NaN"},
+		{Key: "group", Group: []Attr{{Key: "bool", Value: true}}},
+	}, line.Attrs)
+	require.NotNil(t, line.Source)
+	require.Contains(t, line.Source.Function, "TestHandler")
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("renders as originally logged", func(t *testing.T) {
+		var originalBuffer, sessionLogBuffer, replayBuffer bytes.Buffer
+		logger := slog.New(log.NewMultiHandler(
+			newTestTreeHandler(&originalBuffer, slog.LevelDebug),
+			NewHandler(&sessionLogBuffer, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		)).With("((o)) Resonance", "v1")
+
+		applyLogger := logger.WithGroup("✏️ Apply").With("path", "blueprint.yaml").With("host", "localhost")
+		applyLogger.Info("Applying", "int", -1, "uint", uint64(math.MaxUint64), "float", 1.5)
+		fileLogger := applyLogger.WithGroup("📄 File").With("path", "/tmp/foo")
+		fileLogger.Debug(
+			"Diff",
+			"diff", log.NewTerminalValue("\033[31m-foo\033[0m\n\033[32m+bar\033[0m"),
+			"duration", 3*time.Second,
+			"err", errors.New("failed"),
+			slog.Group("group", "bool", true, "multi", "line\nvalue"),
+		)
+		fileLogger.Warn("Warning")
+		applyLogger.With("store", "local").Info("🎆 Apply successful", "changed", 1)
+		logger.Error("Failed")
+
+		require.NoError(t, Replay(ctx, &sessionLogBuffer, newTestTreeHandler(&replayBuffer, slog.LevelDebug)))
+		require.Equal(t, originalBuffer.String(), replayBuffer.String())
+	})
+
+	t.Run("honors level", func(t *testing.T) {
+		var sessionLogBuffer, replayBuffer bytes.Buffer
+		logger := slog.New(NewHandler(&sessionLogBuffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		logger.Debug("debug message")
+		logger.Info("info message")
+
+		require.NoError(t, Replay(ctx, &sessionLogBuffer, newTestTreeHandler(&replayBuffer, slog.LevelInfo)))
+		require.NotContains(t, replayBuffer.String(), "debug message")
+		require.Contains(t, replayBuffer.String(), "info message")
+	})
+
+	t.Run("not a session log", func(t *testing.T) {
+		var replayBuffer bytes.Buffer
+		err := Replay(
+			ctx, strings.NewReader("2025-01-01T00:00:00Z INFO Apply\n"),
+			newTestTreeHandler(&replayBuffer, slog.LevelDebug),
+		)
+		require.ErrorContains(t, err, "line 1: not a session log line")
+	})
+}
@@ -1,14 +1,204 @@
 package store
 
 import (
+	"io"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 
 	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/resources"
 )
 
 func testStore(t *testing.T, store Store) {
-	ctx := t.Context()
-	_ = log.WithTestLogger(ctx)
+	ctx := log.WithTestLogger(t.Context())
+
+	t.Run("Logs", func(t *testing.T) {
+		logInfos, err := store.ListLogs(ctx)
+		require.NoError(t, err)
+		require.Empty(t, logInfos)
+
+		logWriterCloser, err := store.GetLogWriterCloser(ctx, "test")
+		require.NoError(t, err)
+		_, err = logWriterCloser.Write([]byte("foo\n"))
+		require.NoError(t, err)
+		require.NoError(t, logWriterCloser.Close())
+
+		logInfos, err = store.ListLogs(ctx)
+		require.NoError(t, err)
+		require.Len(t, logInfos, 1)
+		require.Regexp(t, `^[0-9]{14}\.test$`, logInfos[0].Name)
+		require.Positive(t, logInfos[0].Size)
+
+		logReadCloser, err := store.GetLogReadCloser(ctx, logInfos[0].Name)
+		require.NoError(t, err)
+		logBytes, err := io.ReadAll(logReadCloser)
+		require.NoError(t, err)
+		require.NoError(t, logReadCloser.Close())
+		require.Equal(t, "foo\n", string(logBytes))
+
+		_, err = store.GetLogReadCloser(ctx, "non-existent")
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		_, err = store.GetLogReadCloser(ctx, "../state/v1/state.yaml")
+		require.Error(t, err)
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		require.NoError(t, store.Lock(ctx))
+
+		var lockedErr *LockedError
+		require.ErrorAs(t, store.Lock(ctx), &lockedErr)
+		require.NotNil(t, lockedErr.LockInfo)
+		require.Equal(t, os.Getpid(), lockedErr.LockInfo.PID)
+
+		require.NoError(t, store.Unlock(ctx))
+		require.NoError(t, store.Lock(ctx))
+
+		require.NoError(t, store.ForceUnlock(ctx))
+		require.NoError(t, store.ForceUnlock(ctx))
+		require.NoError(t, store.Lock(ctx))
+		require.NoError(t, store.Unlock(ctx))
+	})
+
+	t.Run("State", func(t *testing.T) {
+		state, err := store.LoadState(ctx)
+		require.NoError(t, err)
+		require.Nil(t, state)
+
+		contents := "foo"
+		state = &State{
+			Blueprint: &blueprintPkg.Blueprint{
+				Entries: []*blueprintPkg.Entry{
+					{
+						TypeName: "File",
+						Resource: &resources.File{Path: "/foo", RegularFile: &contents},
+					},
+					{
+						TypeName: "APTPackage",
+						Resource: &resources.APTPackage{Package: "vim"},
+					},
+				},
+			},
+			PreExisting: &blueprintPkg.Blueprint{
+				Entries: []*blueprintPkg.Entry{
+					{
+						TypeName: "File",
+						Resource: &resources.File{Path: "/foo", Absent: true},
+					},
+					{
+						TypeName: "APTPackage",
+						Resource: &resources.APTPackage{Package: "vim", Version: "1.0"},
+					},
+				},
+			},
+			Original: &blueprintPkg.Blueprint{
+				Entries: []*blueprintPkg.Entry{
+					{
+						TypeName: "File",
+						Resource: &resources.File{Path: "/foo", Absent: true},
+					},
+				},
+			},
+			Applied: &blueprintPkg.Blueprint{
+				Entries: []*blueprintPkg.Entry{
+					{
+						TypeName: "File",
+						Resource: &resources.File{Path: "/foo", RegularFile: &contents},
+					},
+					{
+						TypeName: "APTPackage",
+						Resource: &resources.APTPackage{Package: "vim", Version: "1.0"},
+					},
+				},
+			},
+		}
+		require.NoError(t, store.SaveState(ctx, state, &Metadata{
+			Time:              time.Unix(1, 0).UTC(),
+			Version:           "v1",
+			BlueprintChecksum: "sha256:1",
+			Changed:           []string{"File:/foo", "APTPackage:vim"},
+		}))
+
+		loadedState, err := store.LoadState(ctx)
+		require.NoError(t, err)
+		requireStateEqual(t, state, loadedState)
+
+		state.Blueprint.Entries = state.Blueprint.Entries[:1]
+		state.PreExisting.Entries = state.PreExisting.Entries[:1]
+		state.Applied.Entries = state.Applied.Entries[:1]
+		require.NoError(t, store.SaveState(ctx, state, &Metadata{
+			Time:              time.Unix(2, 0).UTC(),
+			Version:           "v2",
+			BlueprintChecksum: "sha256:2",
+			Changed:           []string{},
+			InSync:            1,
+		}))
+
+		loadedState, err = store.LoadState(ctx)
+		require.NoError(t, err)
+		requireStateEqual(t, state, loadedState)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		metadatas, err := store.ListHistory(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []*Metadata{
+			{
+				ID:                1,
+				Time:              time.Unix(1, 0).UTC(),
+				Version:           "v1",
+				BlueprintChecksum: "sha256:1",
+				Changed:           []string{"File:/foo", "APTPackage:vim"},
+			},
+			{
+				ID:                2,
+				Time:              time.Unix(2, 0).UTC(),
+				Version:           "v2",
+				BlueprintChecksum: "sha256:2",
+				Changed:           []string{},
+				InSync:            1,
+			},
+		}, metadatas)
+
+		historyEntry, err := store.LoadHistory(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, historyEntry)
+		require.Equal(t, *metadatas[0], historyEntry.Metadata)
+		require.Len(t, historyEntry.State.Blueprint.Entries, 2)
+
+		historyEntry, err = store.LoadHistory(ctx, 2)
+		require.NoError(t, err)
+		require.NotNil(t, historyEntry)
+		require.Equal(t, *metadatas[1], historyEntry.Metadata)
+		require.Len(t, historyEntry.State.Blueprint.Entries, 1)
+
+		historyEntry, err = store.LoadHistory(ctx, 3)
+		require.NoError(t, err)
+		require.Nil(t, historyEntry)
+	})
+}
+
+func requireBlueprintEqual(t *testing.T, expected, actual *blueprintPkg.Blueprint) {
+	require.Len(t, actual.Entries, len(expected.Entries))
+	for i, entry := range expected.Entries {
+		require.Equal(t, entry.TypeName, actual.Entries[i].TypeName)
+		require.Equal(t, entry.Resource, actual.Entries[i].Resource)
+	}
+}
 
-	t.SkipNow()
+func requireStateEqual(t *testing.T, expected, actual *State) {
+	require.NotNil(t, actual)
+	requireBlueprintEqual(t, expected.Blueprint, actual.Blueprint)
+	requireBlueprintEqual(t, expected.PreExisting, actual.PreExisting)
+	requireBlueprintEqual(t, expected.Original, actual.Original)
+	if expected.Applied == nil {
+		require.Nil(t, actual.Applied)
+	} else {
+		requireBlueprintEqual(t, expected.Applied, actual.Applied)
+	}
 }
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// ErrWrongEncryptionKey is returned when encrypted data can not be decrypted with the given key.
+var ErrWrongEncryptionKey = errors.New("failed to decrypt: wrong encryption key, or data is corrupted")
+
+// ErrEncryptedDataCorrupted is returned when encrypted data was partially decrypted with the given
+// key, but is then corrupted or truncated.
+var ErrEncryptedDataCorrupted = errors.New("failed to decrypt: encrypted data is corrupted or truncated")
+
+// ErrEncryptionKeyRequired is returned when reading encrypted data without an encryption key.
+var ErrEncryptionKeyRequired = errors.New("data is encrypted: an encryption key is required")
+
+// ErrNotEncrypted is returned when reading data which is not encrypted with an encryption key,
+// unless Cipher.AllowUnencrypted is set.
+var ErrNotEncrypted = errors.New("data is not encrypted, but an encryption key was given")
+
+// encryptedMagic prefixes all encrypted data. It is followed by frames, each with a big endian
+// uint32 length, a flags byte, and the nonce followed by the sealed data. The last frame has the
+// frameFinal flag set.
+//
+// Each frame is sealed with additional data holding the name of the document, the index of the
+// frame and its flags, so that frames can not be moved between documents, reordered, dropped or
+// have the data after them truncated without being detected.
+var encryptedMagic = []byte("resonance-encrypted-v2\n")
+
+// frameFinal is the flag set at the last frame.
+const frameFinal byte = 1
+
+// maxFrameSize is the maximum size of an encrypted frame.
+const maxFrameSize = 64 << 20
+
+// EncryptionKeySize is the size of encryption keys, in bytes.
+const EncryptionKeySize = 32
+
+// ParseEncryptionKey parses a base64 encoded encryption key, eg: as generated with
+// `head -c 32 /dev/urandom | base64`.
+func ParseEncryptionKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key must have %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// Cipher does authenticated encryption of store data with AES-256-GCM.
+type Cipher struct {
+	// AllowUnencrypted makes data which is not encrypted be read as is, with a warning, instead of
+	// failing with ErrNotEncrypted, so that encryption can be enabled for existing stores.
+	AllowUnencrypted bool
+	aead             cipher.AEAD
+}
+
+// NewCipher creates a new Cipher with given key, which must have EncryptionKeySize bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key must have %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// getFrameAdditionalData returns the additional data a frame is sealed with.
+func getFrameAdditionalData(name string, index uint64, flags byte) []byte {
+	additionalData := binary.BigEndian.AppendUint64(nil, index)
+	additionalData = append(additionalData, flags)
+	return append(additionalData, name...)
+}
+
+func (c *Cipher) sealFrame(name string, index uint64, flags byte, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, getFrameAdditionalData(name, index, flags))
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	frame = append(frame, flags)
+	return append(frame, sealed...), nil
+}
+
+func (c *Cipher) openFrame(name string, index uint64, flags byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("frame is too short")
+	}
+	return c.aead.Open(
+		nil, sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():],
+		getFrameAdditionalData(name, index, flags),
+	)
+}
+
+// encryptingWriter encrypts each Write as a frame, with a single Write to the underlying writer.
+// Close writes the final frame, without closing the underlying writer.
+type encryptingWriter struct {
+	writer io.Writer
+	cipher *Cipher
+	name   string
+	index  uint64
+}
+
+func (w *encryptingWriter) writeFrame(flags byte, p []byte) error {
+	frame, err := w.cipher.sealFrame(w.name, w.index, flags, p)
+	if err != nil {
+		return err
+	}
+	if w.index == 0 {
+		frame = append(append([]byte{}, encryptedMagic...), frame...)
+	}
+	if _, err := w.writer.Write(frame); err != nil {
+		return err
+	}
+	w.index++
+	return nil
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	if err := w.writeFrame(0, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *encryptingWriter) Close() error {
+	return w.writeFrame(frameFinal, nil)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (w nopWriteCloser) Close() error {
+	return nil
+}
+
+// newEncodingWriter returns a writer which encrypts all data written to it with cipher, for the
+// document with given name. It must be closed after all data is written, which does not close
+// writer. If cipher is nil, data is written as is.
+func newEncodingWriter(cipher *Cipher, name string, writer io.Writer) io.WriteCloser {
+	if cipher == nil {
+		return nopWriteCloser{Writer: writer}
+	}
+	return &encryptingWriter{writer: writer, cipher: cipher, name: name}
+}
+
+// decryptingReader decrypts frames written by encryptingWriter.
+type decryptingReader struct {
+	reader *bufio.Reader
+	cipher *Cipher
+	name   string
+	index  uint64
+	final  bool
+	buffer []byte
+}
+
+func (r *decryptingReader) readFrame() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: final frame is missing", ErrEncryptedDataCorrupted)
+		}
+		return err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxFrameSize {
+		return fmt.Errorf("%w: frame %d is too large", ErrEncryptedDataCorrupted, r.index)
+	}
+	flags := header[4]
+	if flags&^frameFinal != 0 {
+		return fmt.Errorf("%w: frame %d has invalid flags", ErrEncryptedDataCorrupted, r.index)
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: frame %d is truncated", ErrEncryptedDataCorrupted, r.index)
+		}
+		return err
+	}
+	plaintext, err := r.cipher.openFrame(r.name, r.index, flags, sealed)
+	if err != nil {
+		// Failing on the first frame is most likely due to the wrong key, but after that, the
+		// key is known to be right.
+		if r.index == 0 {
+			return ErrWrongEncryptionKey
+		}
+		return fmt.Errorf("%w: frame %d: %w", ErrEncryptedDataCorrupted, r.index, err)
+	}
+	r.buffer = plaintext
+	r.final = flags&frameFinal != 0
+	r.index++
+	return nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if r.final {
+			if _, err := r.reader.ReadByte(); err == nil {
+				return 0, fmt.Errorf("%w: data after final frame", ErrEncryptedDataCorrupted)
+			} else if !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		if err := r.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+type decodingReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *decodingReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// newDecodingReadCloser returns a io.ReadCloser which decrypts data from readCloser with cipher,
+// for the document with given name. Data which is not encrypted is read as is when cipher is nil,
+// or with a warning when Cipher.AllowUnencrypted is set.
+func newDecodingReadCloser(
+	ctx context.Context, cipher *Cipher, name string, readCloser io.ReadCloser,
+) (io.ReadCloser, error) {
+	reader := bufio.NewReader(readCloser)
+	magic, err := reader.Peek(len(encryptedMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Join(err, readCloser.Close())
+	}
+	if !bytes.Equal(magic, encryptedMagic) {
+		if cipher != nil {
+			if !cipher.AllowUnencrypted {
+				return nil, errors.Join(ErrNotEncrypted, readCloser.Close())
+			}
+			logger := log.MustLogger(ctx)
+			logger.Warn("Reading data which is not encrypted", "name", name)
+		}
+		return &decodingReadCloser{Reader: reader, closer: readCloser}, nil
+	}
+	if cipher == nil {
+		return nil, errors.Join(ErrEncryptionKeyRequired, readCloser.Close())
+	}
+	if _, err := reader.Discard(len(encryptedMagic)); err != nil {
+		return nil, errors.Join(err, readCloser.Close())
+	}
+	return &decodingReadCloser{
+		Reader: &decryptingReader{reader: reader, cipher: cipher, name: name},
+		closer: readCloser,
+	}, nil
+}
+
+// encode encrypts data of the document with given name with cipher. If cipher is nil, data is
+// returned as is.
+func encode(cipher *Cipher, name string, data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writeCloser := newEncodingWriter(cipher, name, &buffer)
+	if _, err := writeCloser.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writeCloser.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decode decrypts data of the document with given name encoded by encode.
+func decode(ctx context.Context, cipher *Cipher, name string, data []byte) ([]byte, error) {
+	readCloser, err := newDecodingReadCloser(ctx, cipher, name, io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(readCloser)
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+func newTestCipher(t *testing.T, b byte) *Cipher {
+	cipher, err := NewCipher(bytes.Repeat([]byte{b}, EncryptionKeySize))
+	require.NoError(t, err)
+	return cipher
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, EncryptionKeySize)
+	parsedKey, err := ParseEncryptionKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	require.NoError(t, err)
+	require.Equal(t, key, parsedKey)
+
+	_, err = ParseEncryptionKey("not base64!")
+	require.ErrorContains(t, err, "must be base64 encoded")
+
+	_, err = ParseEncryptionKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.ErrorContains(t, err, "must have 32 bytes, got 5")
+}
+
+func TestEncryption(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	cipher := newTestCipher(t, 1)
+	plaintext := []byte("secret")
+
+	t.Run("encode and decode", func(t *testing.T) {
+		data, err := encode(cipher, "test.yaml", plaintext)
+		require.NoError(t, err)
+		require.NotContains(t, string(data), "secret")
+
+		decoded, err := decode(ctx, cipher, "test.yaml", data)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, decoded)
+	})
+
+	t.Run("no cipher", func(t *testing.T) {
+		data, err := encode(nil, "test.yaml", plaintext)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, data)
+
+		decoded, err := decode(ctx, nil, "test.yaml", data)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, decoded)
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		_, err := decode(ctx, cipher, "test.yaml", plaintext)
+		require.ErrorIs(t, err, ErrNotEncrypted)
+
+		allowUnencryptedCipher := newTestCipher(t, 1)
+		allowUnencryptedCipher.AllowUnencrypted = true
+		decoded, err := decode(ctx, allowUnencryptedCipher, "test.yaml", plaintext)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, decoded)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		data, err := encode(cipher, "test.yaml", plaintext)
+		require.NoError(t, err)
+
+		_, err = decode(ctx, newTestCipher(t, 2), "test.yaml", data)
+		require.ErrorIs(t, err, ErrWrongEncryptionKey)
+	})
+
+	t.Run("key required", func(t *testing.T) {
+		data, err := encode(cipher, "test.yaml", plaintext)
+		require.NoError(t, err)
+
+		_, err = decode(ctx, nil, "test.yaml", data)
+		require.ErrorIs(t, err, ErrEncryptionKeyRequired)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := newEncodingWriter(cipher, "test.log", &buffer)
+		for _, chunk := range []string{"foo", "bar", "baz"} {
+			_, err := writer.Write([]byte(chunk))
+			require.NoError(t, err)
+		}
+		require.NoError(t, writer.Close())
+
+		readCloser, err := newDecodingReadCloser(ctx, cipher, "test.log", io.NopCloser(&buffer))
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(readCloser)
+		require.NoError(t, err)
+		require.NoError(t, readCloser.Close())
+		require.Equal(t, "foobarbaz", string(decoded))
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := newEncodingWriter(cipher, "test.log", &buffer)
+		frames := [][]byte{}
+		for _, chunk := range []string{"foo", "bar"} {
+			_, err := writer.Write([]byte(chunk))
+			require.NoError(t, err)
+			frames = append(frames, bytes.Clone(buffer.Bytes()))
+			buffer.Reset()
+		}
+		require.NoError(t, writer.Close())
+		frames = append(frames, bytes.Clone(buffer.Bytes()))
+		// frames[0] is prefixed with encryptedMagic
+		fooFrame := frames[0][len(encryptedMagic):]
+		barFrame := frames[1]
+		finalFrame := frames[2]
+
+		join := func(frames ...[]byte) []byte {
+			return bytes.Join(append([][]byte{encryptedMagic}, frames...), nil)
+		}
+		oversizedFrame := bytes.Clone(barFrame)
+		binary.BigEndian.PutUint32(oversizedFrame, maxFrameSize+1)
+		tamperedFrame := bytes.Clone(barFrame)
+		tamperedFrame[len(tamperedFrame)-1] ^= 1
+
+		decoded, err := decode(ctx, cipher, "test.log", join(fooFrame, barFrame, finalFrame))
+		require.NoError(t, err)
+		require.Equal(t, "foobar", string(decoded))
+
+		for name, data := range map[string][]byte{
+			"final frame missing":    join(fooFrame, barFrame),
+			"frame dropped":          join(fooFrame, finalFrame),
+			"frames reordered":       join(fooFrame, finalFrame, barFrame),
+			"truncated header":       join(fooFrame, barFrame[:3]),
+			"truncated frame":        join(fooFrame, barFrame[:len(barFrame)-1]),
+			"oversized frame":        join(fooFrame, oversizedFrame),
+			"tampered frame":         join(fooFrame, tamperedFrame),
+			"data after final frame": append(join(fooFrame, barFrame, finalFrame), 0),
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := decode(ctx, cipher, "test.log", data)
+				require.ErrorIs(t, err, ErrEncryptedDataCorrupted)
+				require.NotErrorIs(t, err, ErrWrongEncryptionKey)
+			})
+		}
+
+		t.Run("other document", func(t *testing.T) {
+			_, err := decode(ctx, cipher, "other.log", join(fooFrame, barFrame, finalFrame))
+			require.ErrorIs(t, err, ErrWrongEncryptionKey)
+		})
+	})
+}
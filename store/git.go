@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fornellas/resonance/host/lib"
+	"github.com/fornellas/resonance/host/types"
+)
+
+// GitStore is a Store that keeps state and logs at a git repository, with a directory per
+// host, as a HostStore. All changes from a session are committed when its log is closed, with a
+// commit message summarizing the changes.
+// Git is run at Host with the environment of the current process, so Host is expected to be
+// local, and the user's git configuration is honoured.
+type GitStore struct {
+	*HostStore
+	repoPath string
+	hostDir  string
+	metadata *Metadata
+}
+
+// NewGitStore creates a new GitStore for the repository at repoPath on given host, storing state
+// for hostName.
+func NewGitStore(host types.Host, repoPath, hostName string) *GitStore {
+	hostDir := strings.ReplaceAll(hostName, "/", "_")
+	return &GitStore{
+		HostStore: NewHostStore(host, filepath.Join(repoPath, hostDir)),
+		repoPath:  repoPath,
+		hostDir:   hostDir,
+	}
+}
+
+// git runs git at the repository. When it fails, the WaitStatus is returned along with the
+// error.
+func (s *GitStore) git(ctx context.Context, args ...string) (types.WaitStatus, error) {
+	cmd := types.Cmd{
+		Path: "git",
+		Args: args,
+		Env:  os.Environ(),
+		Dir:  s.repoPath,
+	}
+	waitStatus, stdout, stderr, err := lib.Run(ctx, s.Host, cmd)
+	if err != nil {
+		return waitStatus, fmt.Errorf("failed to run %s: %w", cmd, err)
+	}
+	if !waitStatus.Success() {
+		return waitStatus, fmt.Errorf("%s failed: %s\nSTDOUT:\n%s\nSTDERR:\n%s", cmd, waitStatus.String(), stdout, stderr)
+	}
+	return waitStatus, nil
+}
+
+func (s *GitStore) init(ctx context.Context) error {
+	if err := lib.MkdirAll(ctx, s.Host, s.repoPath, 0700); err != nil {
+		return err
+	}
+	if _, err := s.Host.Lstat(ctx, filepath.Join(s.repoPath, ".git")); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	_, err := s.git(ctx, "init", "--quiet")
+	return err
+}
+
+func (s *GitStore) commitMessage(name string) string {
+	if s.metadata == nil {
+		return fmt.Sprintf("%s: %s session", s.hostDir, name)
+	}
+	var message strings.Builder
+	fmt.Fprintf(
+		&message, "%s: %s: %d changed, %d in sync\n\n",
+		s.hostDir, name, len(s.metadata.Changed), s.metadata.InSync,
+	)
+	for _, changed := range s.metadata.Changed {
+		fmt.Fprintf(&message, "- %s\n", changed)
+	}
+	if len(s.metadata.Changed) > 0 {
+		fmt.Fprintf(&message, "\n")
+	}
+	fmt.Fprintf(&message, "Blueprint-Checksum: %s\n", s.metadata.BlueprintChecksum)
+	fmt.Fprintf(&message, "Resonance-Version: %s\n", s.metadata.Version)
+	return message.String()
+}
+
+// commit all state and log changes for the host, if any.
+func (s *GitStore) commit(ctx context.Context, name string) error {
+	if _, err := s.git(
+		ctx, "add", "--all", "--", s.hostDir,
+		// The lock, and stale locks being taken over
+		fmt.Sprintf(":(exclude,glob)%s/**", filepath.Join(s.hostDir, "lock*")),
+	); err != nil {
+		return err
+	}
+
+	waitStatus, err := s.git(ctx, "diff", "--cached", "--quiet")
+	if err == nil {
+		return nil
+	}
+	if !waitStatus.Exited || waitStatus.ExitCode != 1 {
+		return err
+	}
+
+	args := []string{}
+	if waitStatus, err := s.git(ctx, "config", "user.email"); err != nil {
+		if !waitStatus.Exited || waitStatus.ExitCode != 1 {
+			return err
+		}
+		args = append(args, "-c", "user.name=resonance", "-c", "user.email=resonance@localhost")
+	}
+	args = append(args, "commit", "--quiet", "--message", s.commitMessage(name))
+	_, err = s.git(ctx, args...)
+	return err
+}
+
+func (s *GitStore) SaveState(ctx context.Context, state *State, metadata *Metadata) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+	if err := s.HostStore.SaveState(ctx, state, metadata); err != nil {
+		return err
+	}
+	s.metadata = metadata
+	return nil
+}
+
+type gitCommitWriteCloser struct {
+	io.WriteCloser
+	ctx   context.Context
+	store *GitStore
+	name  string
+}
+
+func (wc *gitCommitWriteCloser) Close() error {
+	if err := wc.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return wc.store.commit(wc.ctx, wc.name)
+}
+
+// GetLogWriterCloser returns a log writer which, when closed, commits all changes from the
+// session.
+func (s *GitStore) GetLogWriterCloser(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+	writeCloser, err := s.HostStore.GetLogWriterCloser(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &gitCommitWriteCloser{
+		WriteCloser: writeCloser,
+		ctx:         ctx,
+		store:       s,
+		name:        name,
+	}, nil
+}
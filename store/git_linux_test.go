@@ -0,0 +1,59 @@
+package store
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	hostPkg "github.com/fornellas/resonance/host"
+)
+
+func TestGitStore(t *testing.T) {
+	host := hostPkg.Local{}
+
+	store := NewGitStore(host, t.TempDir(), "foo/bar")
+
+	testStore(t, store)
+}
+
+func TestGitStoreCommit(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+	repoPath := t.TempDir()
+
+	store := NewGitStore(host, repoPath, "foo")
+
+	logWriterCloser, err := store.GetLogWriterCloser(ctx, "apply")
+	require.NoError(t, err)
+	require.NoError(t, store.Lock(ctx))
+	require.NoError(t, store.SaveState(ctx, &State{Blueprint: &blueprintPkg.Blueprint{}}, &Metadata{
+		Time:              time.Unix(1, 0).UTC(),
+		Version:           "v1",
+		BlueprintChecksum: "sha256:1",
+		Changed:           []string{"File:/foo"},
+		InSync:            2,
+	}))
+	_, err = logWriterCloser.Write([]byte("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, logWriterCloser.Close())
+	require.NoError(t, store.Unlock(ctx))
+
+	output, err := exec.Command("git", "-C", repoPath, "log", "--format=%B").CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.Equal(t,
+		"foo: apply: 1 changed, 2 in sync\n\n- File:/foo\n\nBlueprint-Checksum: sha256:1\nResonance-Version: v1\n\n",
+		string(output),
+	)
+
+	output, err = exec.Command("git", "-C", repoPath, "ls-files").CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.Contains(t, string(output), filepath.Join("foo", "state", "v1", "state.yaml"))
+	require.Contains(t, string(output), filepath.Join("foo", "logs"))
+	require.NotContains(t, string(output), filepath.Join("foo", "lock"))
+}
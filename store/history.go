@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+)
+
+// Metadata describes an apply which saved a State.
+type Metadata struct {
+	// ID of the history entry, assigned by the store when saving, increasing with each apply.
+	ID int `yaml:"id"`
+	// Time when the apply started.
+	Time time.Time `yaml:"time"`
+	// Version of resonance used.
+	Version string `yaml:"version"`
+	// BlueprintChecksum is the checksum of the applied blueprint, as in blueprint.Blueprint.Checksum.
+	BlueprintChecksum string `yaml:"blueprint_checksum"`
+	// Changed holds all resources changed by the apply, in the format "TypeName:ID".
+	Changed []string `yaml:"changed"`
+	// InSync is the number of resources which were already in sync.
+	InSync int `yaml:"in_sync"`
+}
+
+// NewMetadata returns the Metadata for the apply of blueprint, started at startTime, which changed
+// and found in sync the resources with given IDs, in the format "TypeName:ID".
+func NewMetadata(
+	startTime time.Time, version string, blueprint *blueprintPkg.Blueprint, changed, inSync []string,
+) (*Metadata, error) {
+	checksum, err := blueprint.Checksum()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blueprint checksum: %w", err)
+	}
+	return &Metadata{
+		Time:              startTime.UTC(),
+		Version:           version,
+		BlueprintChecksum: checksum,
+		Changed:           append([]string{}, changed...),
+		InSync:            len(inSync),
+	}, nil
+}
+
+// getNextHistoryID returns the ID for a new history entry, after existing ids, sorted.
+func getNextHistoryID(ids []int) int {
+	if len(ids) == 0 {
+		return 1
+	}
+	return ids[len(ids)-1] + 1
+}
+
+// getPrunedHistoryIDs returns, from ids sorted oldest first, the ones which must be deleted to keep
+// only the newest historySize entries. The newest entry, just saved, is always kept, even if
+// historySize is less than 1.
+func getPrunedHistoryIDs(ids []int, historySize int) []int {
+	historySize = max(historySize, 1)
+	if len(ids) <= historySize {
+		return []int{}
+	}
+	return ids[:len(ids)-historySize]
+}
+
+// HistoryEntry is a State saved by a past apply, along with its Metadata.
+type HistoryEntry struct {
+	Metadata `yaml:",inline"`
+	State    *State `yaml:"state"`
+}
+
+// getHistoryFileName returns the name of the file which holds the history entry with given id.
+func getHistoryFileName(id int) string {
+	return fmt.Sprintf("%d.yaml", id)
+}
+
+// parseHistoryIDs returns the sorted IDs of all history entry files from names, ignoring other
+// files.
+func parseHistoryIDs(names []string) []int {
+	ids := []int{}
+	for _, name := range names {
+		idStr, ok := strings.CutSuffix(name, ".yaml")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -1,38 +1,503 @@
 package store
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+
 	"github.com/fornellas/resonance/host/lib"
 	"github.com/fornellas/resonance/host/types"
 )
 
-// Implementation of Store that persists Blueprints at a Host at Path.
+// Implementation of Store that persists Blueprints at a Host at Path. It is also used for the
+// local store, with a local Host, so that both lock the same way.
 type HostStore struct {
-	Host      types.Host
-	logPath   string
-	statePath string
+	Host types.Host
+	// HistorySize is the number of history entries to keep, at least 1.
+	HistorySize int
+	// LogRetention defines which session logs are kept.
+	LogRetention LogRetention
+	// Cipher, when set, is used to encrypt state, history and logs.
+	Cipher *Cipher
+	// Integrity configures integrity protection of state and history.
+	Integrity Integrity
+	// lockInfo identifies this session, while holding the lock.
+	lockInfo    *LockInfo
+	path        string
+	lockPath    string
+	logPath     string
+	statePath   string
+	historyPath string
 }
 
+// DefaultHistorySize is the default value for HostStore.HistorySize.
+var DefaultHistorySize = 10
+
 // NewHostStore creates a new HostStore for given Host.
 func NewHostStore(host types.Host, path string) *HostStore {
+	// v1 is the version of the directory layout; documents record their own SchemaVersion, and
+	// are migrated when loaded.
 	basePath := filepath.Join(path, "state", "v1")
 	return &HostStore{
-		Host:      host,
-		logPath:   filepath.Join(path, "logs"),
-		statePath: basePath,
+		Host:         host,
+		HistorySize:  DefaultHistorySize,
+		LogRetention: DefaultLogRetention,
+		path:         path,
+		lockPath:     filepath.Join(path, "lock"),
+		logPath:      filepath.Join(path, "logs"),
+		statePath:    basePath,
+		historyPath:  filepath.Join(basePath, "history"),
 	}
 }
 
-func (s *HostStore) deleteOldLogs(ctx context.Context) error {
+func getLockInfoPath(lockPath string) string {
+	return filepath.Join(lockPath, "info.yaml")
+}
+
+// lockWithoutInfoGracePeriod is for how long a lock without readable LockInfo is held: the holder
+// writes it right after creating the lock, so when it is missing for longer, the holder is gone.
+// It is generous, as it is compared with the lock modification time, from the store host clock.
+var lockWithoutInfoGracePeriod = 5 * time.Minute
+
+// loadLockInfo returns the LockInfo of the holder of the lock at lockPath, or nil, when not
+// available or unreadable.
+func (s *HostStore) loadLockInfo(ctx context.Context, lockPath string) (_ *LockInfo, retErr error) {
+	readCloser, err := s.Host.ReadFile(ctx, getLockInfoPath(lockPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { retErr = errors.Join(retErr, readCloser.Close()) }()
+
+	decoder := yaml.NewDecoder(readCloser)
+	decoder.KnownFields(true)
+	var lockInfo LockInfo
+	if err := decoder.Decode(&lockInfo); err != nil {
+		logger := log.MustLogger(ctx)
+		logger.Warn("Ignoring unreadable lock info", "path", getLockInfoPath(lockPath), "err", err)
+		return nil, nil
+	}
+	return &lockInfo, nil
+}
+
+// isLockWithoutInfoStale returns true when the lock at lockPath, which has no readable LockInfo,
+// was not modified for lockWithoutInfoGracePeriod.
+func (s *HostStore) isLockWithoutInfoStale(ctx context.Context, lockPath string) (bool, error) {
+	stat, err := s.Host.Lstat(ctx, lockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Released meanwhile
+			return false, nil
+		}
+		return false, err
+	}
+	modTime := time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec)
+	return time.Since(modTime) > lockWithoutInfoGracePeriod, nil
+}
+
+func (s *HostStore) removeLock(ctx context.Context, lockPath string) error {
+	if err := s.Host.Remove(ctx, getLockInfoPath(lockPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := s.Host.Remove(ctx, lockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// rename atomically renames oldPath to newPath, which must not exist.
+func (s *HostStore) rename(ctx context.Context, oldPath, newPath string) error {
+	cmd := types.Cmd{
+		Path: "mv",
+		Args: []string{"--", oldPath, newPath},
+	}
+	waitStatus, stdout, stderr, err := lib.Run(ctx, s.Host, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", cmd, err)
+	}
+	if !waitStatus.Success() {
+		return fmt.Errorf(
+			"failed to run %s: %s\nstdout:\n%s\nstderr:\n%s", cmd, waitStatus.String(), stdout, stderr,
+		)
+	}
+	return nil
+}
+
+// takeOverStaleLock takes over the lock from the stale staleLockInfo holder, or from an unknown
+// holder, when nil. The lock directory is atomically renamed aside first, so that only one of many
+// sessions taking over concurrently gets it. As another session may have taken over the lock since
+// staleLockInfo was loaded, the lock renamed aside is checked to still be from the stale holder,
+// and is put back if not.
+func (s *HostStore) takeOverStaleLock(ctx context.Context, staleLockInfo *LockInfo) error {
+	holder := "unknown"
+	if staleLockInfo != nil {
+		holder = staleLockInfo.String()
+	}
+	logger := log.MustLogger(ctx)
+	logger.Warn("Taking over stale lock", "holder", holder)
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return err
+	}
+	stalePath := fmt.Sprintf("%s.stale-%s", s.lockPath, hex.EncodeToString(idBytes))
+	if err := s.rename(ctx, s.lockPath, stalePath); err != nil {
+		if _, lstatErr := s.Host.Lstat(ctx, s.lockPath); errors.Is(lstatErr, os.ErrNotExist) {
+			// Renamed aside by another session taking over
+			return &LockedError{}
+		}
+		return err
+	}
+
+	renamedLockInfo, err := s.loadLockInfo(ctx, stalePath)
+	if err != nil {
+		return errors.Join(err, s.rename(ctx, stalePath, s.lockPath))
+	}
+	var stillStale bool
+	if staleLockInfo == nil {
+		if renamedLockInfo == nil {
+			stillStale, err = s.isLockWithoutInfoStale(ctx, stalePath)
+			if err != nil {
+				return errors.Join(err, s.rename(ctx, stalePath, s.lockPath))
+			}
+		}
+	} else {
+		stillStale = renamedLockInfo != nil && renamedLockInfo.Equal(staleLockInfo)
+	}
+	if !stillStale {
+		if err := s.rename(ctx, stalePath, s.lockPath); err != nil {
+			return fmt.Errorf("failed to restore lock taken over by another session: %w", err)
+		}
+		return &LockedError{LockInfo: renamedLockInfo}
+	}
+
+	if err := s.removeLock(ctx, stalePath); err != nil {
+		return err
+	}
+
+	if err := s.Host.Mkdir(ctx, s.lockPath, 0700); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return &LockedError{}
+		}
+		return err
+	}
+	return nil
+}
+
+// Lock is acquired by atomically creating a lock directory, which then holds the LockInfo. Locks
+// from gone holders at the same host, or without LockInfo for lockWithoutInfoGracePeriod, are
+// taken over.
+func (s *HostStore) Lock(ctx context.Context) error {
+	lockInfo, err := NewLockInfo()
+	if err != nil {
+		return err
+	}
+
+	if err := lib.MkdirAll(ctx, s.Host, filepath.Dir(s.lockPath), 0700); err != nil {
+		return err
+	}
+
+	if err := s.Host.Mkdir(ctx, s.lockPath, 0700); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		holderLockInfo, err := s.loadLockInfo(ctx, s.lockPath)
+		if err != nil {
+			return err
+		}
+		var stale bool
+		if holderLockInfo == nil {
+			stale, err = s.isLockWithoutInfoStale(ctx, s.lockPath)
+		} else {
+			stale, err = holderLockInfo.IsStale()
+		}
+		if err != nil {
+			return err
+		}
+		if !stale {
+			return &LockedError{LockInfo: holderLockInfo}
+		}
+		if err := s.takeOverStaleLock(ctx, holderLockInfo); err != nil {
+			return err
+		}
+	}
+
+	lockInfoBytes, err := yaml.Marshal(lockInfo)
+	if err != nil {
+		return err
+	}
+	if err := s.Host.WriteFile(ctx, getLockInfoPath(s.lockPath), bytes.NewReader(lockInfoBytes), 0600); err != nil {
+		return err
+	}
+	s.lockInfo = lockInfo
+	return nil
+}
+
+// Unlock releases the lock, if still held by this session.
+func (s *HostStore) Unlock(ctx context.Context) error {
+	holderLockInfo, err := s.loadLockInfo(ctx, s.lockPath)
+	if err != nil {
+		return err
+	}
+	if s.lockInfo == nil || holderLockInfo == nil || !holderLockInfo.Equal(s.lockInfo) {
+		return errors.New("lock was taken over by another session")
+	}
+	if err := s.removeLock(ctx, s.lockPath); err != nil {
+		return err
+	}
+	s.lockInfo = nil
+	return nil
+}
+
+func (s *HostStore) ForceUnlock(ctx context.Context) error {
+	if _, err := s.Host.Lstat(ctx, s.lockPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	holder := "unknown"
+	lockInfo, err := s.loadLockInfo(ctx, s.lockPath)
+	if err == nil && lockInfo != nil {
+		holder = lockInfo.String()
+	}
+	logger := log.MustLogger(ctx)
+	logger.Warn("Forcing unlock", "holder", holder)
+	return s.removeLock(ctx, s.lockPath)
+}
+
+// getDocumentName returns the name of the document at path, relative to the store, which its
+// encryption and seal are bound to.
+func (s *HostStore) getDocumentName(path string) string {
+	name, err := filepath.Rel(s.path, path)
+	if err != nil {
+		panic(fmt.Sprintf("bug: %s is not at store %s: %s", path, s.path, err))
+	}
+	return filepath.ToSlash(name)
+}
+
+// writeFile writes document data to path, sealed with Integrity for the apply with sequence and
+// historyID, and encrypted when Cipher is set.
+func (s *HostStore) writeFile(
+	ctx context.Context, path string, data []byte, sequence uint64, historyID int,
+) error {
+	name := s.getDocumentName(path)
+	seal := documentSeal{Name: name, Sequence: sequence, HistoryID: historyID}
+	data, err := encode(s.Cipher, name, s.Integrity.seal(seal, data))
+	if err != nil {
+		return err
+	}
+	return s.Host.WriteFile(ctx, path, bytes.NewReader(data), 0600)
+}
+
+// readFile reads document data from path, decrypting it when encrypted, and verifying its
+// Integrity, returning it along with its seal.
+func (s *HostStore) readFile(ctx context.Context, path string) (_ []byte, _ *documentSeal, retErr error) {
+	readCloser, err := s.Host.ReadFile(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodingReadCloser, err := newDecodingReadCloser(ctx, s.Cipher, s.getDocumentName(path), readCloser)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer func() { retErr = errors.Join(retErr, decodingReadCloser.Close()) }()
+	data, err := io.ReadAll(decodingReadCloser)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s.Integrity.verify(ctx, path, s.getDocumentName(path), data)
+}
+
+// loadSeal returns the seal of the document at path, or nil if it does not exist.
+func (s *HostStore) loadSeal(ctx context.Context, path string) (*documentSeal, error) {
+	_, seal, err := s.readFile(ctx, path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return seal, nil
+}
+
+// loadHistorySeal returns the seal of the newest history entry from ids, or nil if there is none.
+func (s *HostStore) loadHistorySeal(ctx context.Context, ids []int) (*documentSeal, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.loadSeal(ctx, s.getHistoryFilePath(ids[len(ids)-1]))
+}
+
+func (s *HostStore) getStateFilePath() string {
+	return filepath.Join(s.statePath, "state.yaml")
+}
+
+func (s *HostStore) SaveState(ctx context.Context, state *State, metadata *Metadata) error {
+	stateBytes, err := marshalDocument(state)
+	if err != nil {
+		return err
+	}
+
+	if err := lib.MkdirAll(ctx, s.Host, s.statePath, 0700); err != nil {
+		return err
+	}
+
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return err
+	}
+	historySeal, err := s.loadHistorySeal(ctx, ids)
+	if err != nil {
+		return err
+	}
+	stateSeal, err := s.loadSeal(ctx, s.getStateFilePath())
+	if err != nil {
+		return err
+	}
+	sequence := getNextSequence(stateSeal, historySeal)
+	historyEntry := &HistoryEntry{Metadata: *metadata, State: state}
+	historyEntry.ID = getNextHistoryID(ids)
+
+	if err := s.writeFile(ctx, s.getStateFilePath(), stateBytes, sequence, historyEntry.ID); err != nil {
+		return err
+	}
+
+	return s.saveHistory(ctx, ids, historyEntry, sequence)
+}
+
+func (s *HostStore) LoadState(ctx context.Context) (*State, error) {
+	stateBytes, stateSeal, err := s.readFile(ctx, s.getStateFilePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	historySeal, err := s.loadHistorySeal(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Integrity.verifyNotReplayed(ctx, s.getStateFilePath(), stateSeal, historySeal); err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := unmarshalDocument(stateBytes, stateDocument, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", s.getStateFilePath(), err)
+	}
+	return &state, nil
+}
+
+func (s *HostStore) getHistoryFilePath(id int) string {
+	return filepath.Join(s.historyPath, getHistoryFileName(id))
+}
+
+// listHistoryIDs returns the IDs of all history entries, sorted.
+func (s *HostStore) listHistoryIDs(ctx context.Context) ([]int, error) {
+	dirEntResultCh, cancel := s.Host.ReadDir(ctx, s.historyPath)
+	defer cancel()
+
+	names := []string{}
+	for dirEntResult := range dirEntResultCh {
+		if dirEntResult.Error != nil {
+			if errors.Is(dirEntResult.Error, os.ErrNotExist) {
+				return []int{}, nil
+			}
+			return nil, dirEntResult.Error
+		}
+		names = append(names, dirEntResult.DirEnt.Name)
+	}
+	return parseHistoryIDs(names), nil
+}
+
+// saveHistory saves historyEntry, with its ID already set after existing ids, for the apply with
+// sequence, and prunes old entries.
+func (s *HostStore) saveHistory(ctx context.Context, ids []int, historyEntry *HistoryEntry, sequence uint64) error {
+	ids = append(ids, historyEntry.ID)
+
+	historyEntryBytes, err := marshalDocument(historyEntry)
+	if err != nil {
+		return err
+	}
+
+	if err := lib.MkdirAll(ctx, s.Host, s.historyPath, 0700); err != nil {
+		return err
+	}
+
+	if err := s.writeFile(
+		ctx, s.getHistoryFilePath(historyEntry.ID), historyEntryBytes, sequence, historyEntry.ID,
+	); err != nil {
+		return err
+	}
+
+	for _, id := range getPrunedHistoryIDs(ids, s.HistorySize) {
+		if err := s.Host.Remove(ctx, s.getHistoryFilePath(id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *HostStore) LoadHistory(ctx context.Context, id int) (*HistoryEntry, error) {
+	historyEntryBytes, _, err := s.readFile(ctx, s.getHistoryFilePath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var historyEntry HistoryEntry
+	if err := unmarshalDocument(historyEntryBytes, historyEntryDocument, &historyEntry); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", s.getHistoryFilePath(id), err)
+	}
+	return &historyEntry, nil
+}
+
+func (s *HostStore) ListHistory(ctx context.Context) ([]*Metadata, error) {
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadatas := []*Metadata{}
+	for _, id := range ids {
+		historyEntry, err := s.LoadHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if historyEntry == nil {
+			continue
+		}
+		metadatas = append(metadatas, &historyEntry.Metadata)
+	}
+	return metadatas, nil
+}
+
+func (s *HostStore) ListLogs(ctx context.Context) ([]*LogInfo, error) {
 	dirEntResultCh, cancel := s.Host.ReadDir(ctx, s.logPath)
 	defer cancel()
 
@@ -40,9 +505,9 @@ func (s *HostStore) deleteOldLogs(ctx context.Context) error {
 	for dirEntResult := range dirEntResultCh {
 		if dirEntResult.Error != nil {
 			if errors.Is(dirEntResult.Error, os.ErrNotExist) {
-				return nil
+				return []*LogInfo{}, nil
 			}
-			return dirEntResult.Error
+			return nil, dirEntResult.Error
 		}
 		dirEnt := dirEntResult.DirEnt
 		if filepath.Ext(dirEnt.Name) == ".gz" {
@@ -50,16 +515,66 @@ func (s *HostStore) deleteOldLogs(ctx context.Context) error {
 		}
 	}
 
-	if len(names) <= 10 {
-		return nil
+	sort.Strings(names)
+
+	logInfos := []*LogInfo{}
+	for _, name := range names {
+		stat, err := s.Host.Lstat(ctx, filepath.Join(s.logPath, name))
+		if err != nil {
+			return nil, err
+		}
+		logInfos = append(logInfos, &LogInfo{
+			Name:    strings.TrimSuffix(name, ".gz"),
+			Size:    stat.Size,
+			ModTime: time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec),
+		})
 	}
 
-	sort.Strings(names)
+	return logInfos, nil
+}
+
+func (s *HostStore) getLogFilePath(name string) (string, error) {
+	fileName, err := getLogFileName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.logPath, fileName), nil
+}
 
-	namesToDelete := names[:len(names)-10]
+func (s *HostStore) GetLogReadCloser(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := s.getLogFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	readCloser, err := s.Host.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, name := range namesToDelete {
-		path := filepath.Join(s.logPath, name)
+	decodingReadCloser, err := newDecodingReadCloser(ctx, s.Cipher, s.getDocumentName(path), readCloser)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	gzipReadCloser, err := newGzipReadCloser(decodingReadCloser)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to read %s: %w", path, err), decodingReadCloser.Close())
+	}
+	return gzipReadCloser, nil
+}
+
+func (s *HostStore) deleteOldLogs(ctx context.Context) error {
+	logInfos, err := s.ListLogs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, logInfo := range s.LogRetention.filter(logInfos, time.Now()) {
+		path, err := s.getLogFilePath(logInfo.Name)
+		if err != nil {
+			return err
+		}
 		if err := s.Host.Remove(ctx, path); err != nil {
 			return err
 		}
@@ -77,22 +592,18 @@ func (s *HostStore) GetLogWriterCloser(ctx context.Context, name string) (io.Wri
 		return nil, err
 	}
 
-	gzipWriter, err := gzip.NewWriterLevel(
-		&lib.HostFileWriter{
-			Context: ctx,
-			Host:    s.Host,
-			Path: filepath.Join(
-				s.logPath,
-				fmt.Sprintf("%s.%s.gz", time.Now().UTC().Format("20060102150405"), name),
-			),
-		},
-		gzip.BestSpeed,
-	)
+	path := filepath.Join(s.logPath, newLogFileName(name))
+	encodingWriter := newEncodingWriter(s.Cipher, s.getDocumentName(path), &lib.HostFileWriter{
+		Context: ctx,
+		Host:    s.Host,
+		Path:    path,
+	})
+	gzipWriter, err := gzip.NewWriterLevel(encodingWriter, gzip.BestSpeed)
 	if err != nil {
 		return nil, err
 	}
 	if err := gzipWriter.Flush(); err != nil {
 		return nil, err
 	}
-	return gzipWriter, nil
+	return &gzipWriteCloser{Writer: gzipWriter, writeCloser: encodingWriter}, nil
 }
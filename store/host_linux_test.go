@@ -1,8 +1,20 @@
 package store
 
 import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
 	hostPkg "github.com/fornellas/resonance/host"
 )
 
@@ -13,3 +25,261 @@ func TestHostStore(t *testing.T) {
 
 	testStore(t, store)
 }
+
+func TestHostStoreStaleLock(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	store := NewHostStore(host, t.TempDir())
+
+	staleLockInfo, err := NewLockInfo()
+	require.NoError(t, err)
+	staleLockInfo.PID = math.MaxInt32
+	lockInfoBytes, err := yaml.Marshal(staleLockInfo)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(store.lockPath, 0700))
+	require.NoError(t, os.WriteFile(getLockInfoPath(store.lockPath), lockInfoBytes, 0600))
+	staleLockInfo, err = store.loadLockInfo(ctx, store.lockPath)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Lock(ctx))
+
+	lockInfo, err := store.loadLockInfo(ctx, store.lockPath)
+	require.NoError(t, err)
+	require.Equal(t, os.Getpid(), lockInfo.PID)
+
+	t.Run("concurrent take over", func(t *testing.T) {
+		// Another session which also found the stale lock, but takes it over after store did
+		otherStore := NewHostStore(host, filepath.Dir(store.lockPath))
+		var lockedErr *LockedError
+		require.ErrorAs(t, otherStore.takeOverStaleLock(ctx, staleLockInfo), &lockedErr)
+		require.NotNil(t, lockedErr.LockInfo)
+		require.True(t, lockedErr.LockInfo.Equal(lockInfo))
+
+		entries, err := os.ReadDir(filepath.Dir(store.lockPath))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		require.NoError(t, store.Unlock(ctx))
+	})
+}
+
+func TestHostStoreLockWithoutInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		lockInfo []byte
+	}{
+		{name: "missing"},
+		{name: "unreadable", lockInfo: []byte("{")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := log.WithTestLogger(t.Context())
+			host := hostPkg.Local{}
+
+			store := NewHostStore(host, t.TempDir())
+			require.NoError(t, os.MkdirAll(store.lockPath, 0700))
+			if tc.lockInfo != nil {
+				require.NoError(t, os.WriteFile(getLockInfoPath(store.lockPath), tc.lockInfo, 0600))
+			}
+
+			var lockedErr *LockedError
+			require.ErrorAs(t, store.Lock(ctx), &lockedErr)
+			require.Nil(t, lockedErr.LockInfo)
+
+			modTime := time.Now().Add(-lockWithoutInfoGracePeriod - time.Second)
+			require.NoError(t, os.Chtimes(store.lockPath, modTime, modTime))
+			require.NoError(t, store.Lock(ctx))
+
+			lockInfo, err := store.loadLockInfo(ctx, store.lockPath)
+			require.NoError(t, err)
+			require.Equal(t, os.Getpid(), lockInfo.PID)
+
+			entries, err := os.ReadDir(filepath.Dir(store.lockPath))
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+
+			require.NoError(t, store.Unlock(ctx))
+		})
+	}
+}
+
+func TestHostStoreUnlockTakenOver(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+	dir := t.TempDir()
+
+	store := NewHostStore(host, dir)
+	otherStore := NewHostStore(host, dir)
+
+	require.NoError(t, store.Lock(ctx))
+	require.NoError(t, otherStore.ForceUnlock(ctx))
+	require.NoError(t, otherStore.Lock(ctx))
+	require.ErrorContains(t, store.Unlock(ctx), "lock was taken over by another session")
+	require.NoError(t, otherStore.Unlock(ctx))
+}
+
+func TestHostStoreHistorySize(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	store := NewHostStore(host, t.TempDir())
+	store.HistorySize = 2
+
+	state := &State{Blueprint: &blueprintPkg.Blueprint{}}
+	for range 3 {
+		require.NoError(t, store.SaveState(ctx, state, &Metadata{Changed: []string{}}))
+	}
+
+	metadatas, err := store.ListHistory(ctx)
+	require.NoError(t, err)
+	ids := []int{}
+	for _, metadata := range metadatas {
+		ids = append(ids, metadata.ID)
+	}
+	require.Equal(t, []int{2, 3}, ids)
+
+	for _, historySize := range []int{0, -1} {
+		store.HistorySize = historySize
+		require.NoError(t, store.SaveState(ctx, state, &Metadata{Changed: []string{}}))
+		metadatas, err = store.ListHistory(ctx)
+		require.NoError(t, err)
+		require.Len(t, metadatas, 1)
+	}
+}
+
+func TestHostStoreEncrypted(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+	path := t.TempDir()
+
+	store := NewHostStore(host, path)
+	store.Cipher = newTestCipher(t, 1)
+
+	testStore(t, store)
+
+	stateBytes, err := os.ReadFile(store.getStateFilePath())
+	require.NoError(t, err)
+	require.NotContains(t, string(stateBytes), "/foo")
+
+	store = NewHostStore(host, path)
+	store.Cipher = newTestCipher(t, 2)
+	_, err = store.LoadState(ctx)
+	require.ErrorIs(t, err, ErrWrongEncryptionKey)
+
+	store = NewHostStore(host, path)
+	_, err = store.LoadState(ctx)
+	require.ErrorIs(t, err, ErrEncryptionKeyRequired)
+}
+
+func TestHostStoreSchemaVersion(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	store := NewHostStore(host, t.TempDir())
+	require.NoError(t, os.MkdirAll(store.statePath, 0700))
+
+	require.NoError(t, os.WriteFile(store.getStateFilePath(), []byte("blueprint: []\n"), 0600))
+	state, err := store.LoadState(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &State{Blueprint: &blueprintPkg.Blueprint{Entries: []*blueprintPkg.Entry{}}}, state)
+
+	require.NoError(t, os.WriteFile(store.getStateFilePath(), store.Integrity.seal(documentSeal{
+		Name: store.getDocumentName(store.getStateFilePath()), Sequence: 1, HistoryID: 1,
+	}, []byte(fmt.Sprintf(
+		"schema_version: %d\nblueprint: []\n", SchemaVersion+1,
+	))), 0600))
+	_, err = store.LoadState(ctx)
+	var schemaDowngradeError *SchemaDowngradeError
+	require.ErrorAs(t, err, &schemaDowngradeError)
+}
+
+func TestHostStoreIntegrity(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+	path := t.TempDir()
+
+	store := NewHostStore(host, path)
+	store.Integrity = newTestIntegrity(t, 1)
+
+	testStore(t, store)
+
+	stateBytes, err := os.ReadFile(store.getStateFilePath())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(
+		store.getStateFilePath(), bytes.Replace(stateBytes, []byte("/foo"), []byte("/bar"), 1), 0600,
+	))
+	_, err = store.LoadState(ctx)
+	var integrityError *IntegrityError
+	require.ErrorAs(t, err, &integrityError)
+	require.Equal(t, "checksum mismatch", integrityError.Reason)
+
+	store.Integrity.SkipVerification = true
+	state, err := store.LoadState(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/bar", state.Blueprint.Entries[0].Resource.ID())
+
+	store = NewHostStore(host, path)
+	store.Integrity = newTestIntegrity(t, 2)
+	_, err = store.LoadHistory(ctx, 1)
+	require.ErrorAs(t, err, &integrityError)
+	require.Equal(t, "signature is invalid", integrityError.Reason)
+}
+
+func TestHostStoreIntegrityReplay(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+
+	store := NewHostStore(host, t.TempDir())
+	store.Integrity = newTestIntegrity(t, 1)
+
+	blueprint := &blueprintPkg.Blueprint{Entries: []*blueprintPkg.Entry{}}
+	metadata, err := NewMetadata(time.Now(), "test", blueprint, []string{}, []string{})
+	require.NoError(t, err)
+	state := &State{Blueprint: blueprint}
+
+	requireSequence := func(t *testing.T, path string, sequence uint64) {
+		_, documentSeal, err := store.readFile(ctx, path)
+		require.NoError(t, err)
+		require.Equal(t, sequence, documentSeal.Sequence)
+	}
+
+	require.NoError(t, store.SaveState(ctx, state, metadata))
+	oldStateBytes, err := os.ReadFile(store.getStateFilePath())
+	require.NoError(t, err)
+	oldHistoryEntryBytes, err := os.ReadFile(store.getHistoryFilePath(1))
+	require.NoError(t, err)
+	require.NoError(t, store.SaveState(ctx, state, metadata))
+	requireSequence(t, store.getStateFilePath(), 2)
+	requireSequence(t, store.getHistoryFilePath(2), 2)
+
+	t.Run("state", func(t *testing.T) {
+		stateBytes, err := os.ReadFile(store.getStateFilePath())
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, os.WriteFile(store.getStateFilePath(), stateBytes, 0600)) })
+
+		require.NoError(t, os.WriteFile(store.getStateFilePath(), oldStateBytes, 0600))
+		_, err = store.LoadState(ctx)
+		var integrityError *IntegrityError
+		require.ErrorAs(t, err, &integrityError)
+		require.Equal(t, "its sequence 1 is older than 2 from history entry 2, so an older state was put in place", integrityError.Reason)
+	})
+
+	t.Run("history at other path", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(store.getHistoryFilePath(3), oldHistoryEntryBytes, 0600))
+		t.Cleanup(func() { require.NoError(t, os.Remove(store.getHistoryFilePath(3))) })
+
+		_, err := store.LoadHistory(ctx, 3)
+		var integrityError *IntegrityError
+		require.ErrorAs(t, err, &integrityError)
+		require.Equal(t, "it was saved as state/v1/history/1.yaml", integrityError.Reason)
+	})
+
+	t.Run("history pruned", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(store.historyPath))
+		require.NoError(t, store.SaveState(ctx, state, metadata))
+		requireSequence(t, store.getStateFilePath(), 3)
+		requireSequence(t, store.getHistoryFilePath(1), 3)
+		_, err := store.LoadState(ctx)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,534 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// errHTTPPreconditionFailed is returned when a conditional request fails.
+var errHTTPPreconditionFailed = errors.New("precondition failed")
+
+// Implementation of Store that persists state, locks and logs at a HTTP service, such as served
+// by HTTPStoreHandler, with a namespace per host. All changes are conditional on ETags, so
+// concurrent changes are detected.
+type HTTPStore struct {
+	Client *http.Client
+	// HistorySize is the number of history entries to keep, at least 1.
+	HistorySize int
+	// LogRetention defines which session logs are kept.
+	LogRetention LogRetention
+	// Cipher, when set, is used to encrypt state, history and logs.
+	Cipher *Cipher
+	// Integrity configures integrity protection of state and history.
+	Integrity Integrity
+	url       *url.URL
+	lockETag  string
+	stateETag *string
+	// stateSeal is the seal of the state loaded by LoadState, nil if there was none.
+	stateSeal *documentSeal
+}
+
+// NewHTTPStore creates a new HTTPStore for the service at baseURL, storing state for hostName.
+func NewHTTPStore(baseURL, hostName string) (*HTTPStore, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid store URL %#v: scheme must be http or https", baseURL)
+	}
+	return &HTTPStore{
+		Client:       http.DefaultClient,
+		HistorySize:  DefaultHistorySize,
+		LogRetention: DefaultLogRetention,
+		url:          u.JoinPath(url.PathEscape(strings.ReplaceAll(hostName, "/", "_"))),
+	}, nil
+}
+
+func (s *HTTPStore) getURL(name string) string {
+	u := s.url.JoinPath(name)
+	if strings.HasSuffix(name, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+// do sends a request for name with given headers, and returns the response if successful.
+// Non existent names return an error wrapping os.ErrNotExist and failed preconditions return
+// errHTTPPreconditionFailed.
+func (s *HTTPStore) do(
+	ctx context.Context, method, name string, body []byte, header map[string]string,
+) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.getURL(name), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, &fs.PathError{Op: method, Path: req.URL.String(), Err: os.ErrNotExist}
+	case http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%s %s: %w", method, req.URL, errHTTPPreconditionFailed)
+	default:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+}
+
+// get returns the contents of name and its ETag.
+func (s *HTTPStore) get(ctx context.Context, name string) ([]byte, string, error) {
+	resp, err := s.do(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// put replaces the contents of name and returns its new ETag.
+func (s *HTTPStore) put(ctx context.Context, name string, data []byte, header map[string]string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPut, name, data, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *HTTPStore) delete(ctx context.Context, name string, header map[string]string) error {
+	resp, err := s.do(ctx, http.MethodDelete, name, nil, header)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *HTTPStore) list(ctx context.Context, dir string) (_ []httpDirEntry, retErr error) {
+	resp, err := s.do(ctx, http.MethodGet, dir+"/", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { retErr = errors.Join(retErr, resp.Body.Close()) }()
+	httpDirEntries := []httpDirEntry{}
+	if err := json.NewDecoder(resp.Body).Decode(&httpDirEntries); err != nil {
+		return nil, fmt.Errorf("failed to decode %s listing: %w", dir, err)
+	}
+	return httpDirEntries, nil
+}
+
+func (s *HTTPStore) Lock(ctx context.Context) error {
+	lockInfo, err := NewLockInfo()
+	if err != nil {
+		return err
+	}
+	lockInfoBytes, err := yaml.Marshal(lockInfo)
+	if err != nil {
+		return err
+	}
+
+	lockETag, err := s.put(ctx, "lock.yaml", lockInfoBytes, map[string]string{"If-None-Match": "*"})
+	if err == nil {
+		s.lockETag = lockETag
+		return nil
+	}
+	if !errors.Is(err, errHTTPPreconditionFailed) {
+		return err
+	}
+
+	holderLockInfoBytes, holderLockETag, err := s.get(ctx, "lock.yaml")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &LockedError{}
+		}
+		return err
+	}
+	var holderLockInfo LockInfo
+	if err := decodeYAML(holderLockInfoBytes, &holderLockInfo); err != nil {
+		return fmt.Errorf("failed to decode lock: %w", err)
+	}
+	stale, err := holderLockInfo.IsStale()
+	if err != nil {
+		return err
+	}
+	if !stale {
+		return &LockedError{LockInfo: &holderLockInfo}
+	}
+
+	logger := log.MustLogger(ctx)
+	logger.Warn("Taking over stale lock", "holder", holderLockInfo.String())
+	if err := s.delete(ctx, "lock.yaml", map[string]string{"If-Match": holderLockETag}); err != nil {
+		if errors.Is(err, errHTTPPreconditionFailed) || errors.Is(err, os.ErrNotExist) {
+			return &LockedError{}
+		}
+		return err
+	}
+	lockETag, err = s.put(ctx, "lock.yaml", lockInfoBytes, map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		if errors.Is(err, errHTTPPreconditionFailed) {
+			return &LockedError{}
+		}
+		return err
+	}
+	s.lockETag = lockETag
+	return nil
+}
+
+func (s *HTTPStore) Unlock(ctx context.Context) error {
+	if err := s.delete(ctx, "lock.yaml", map[string]string{"If-Match": s.lockETag}); err != nil {
+		if errors.Is(err, errHTTPPreconditionFailed) || errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("lock was taken over by another session: %w", err)
+		}
+		return err
+	}
+	s.lockETag = ""
+	return nil
+}
+
+func (s *HTTPStore) ForceUnlock(ctx context.Context) error {
+	holder := "unknown"
+	holderLockInfoBytes, _, err := s.get(ctx, "lock.yaml")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var holderLockInfo LockInfo
+	if err := decodeYAML(holderLockInfoBytes, &holderLockInfo); err == nil {
+		holder = holderLockInfo.String()
+	}
+	logger := log.MustLogger(ctx)
+	logger.Warn("Forcing unlock", "holder", holder)
+	if err := s.delete(ctx, "lock.yaml", nil); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// encodeDocument returns document data to be saved as name, sealed with Integrity for the apply
+// with sequence and historyID, and encrypted when Cipher is set.
+func (s *HTTPStore) encodeDocument(name string, data []byte, sequence uint64, historyID int) ([]byte, error) {
+	seal := documentSeal{Name: name, Sequence: sequence, HistoryID: historyID}
+	return encode(s.Cipher, name, s.Integrity.seal(seal, data))
+}
+
+// getDocument gets the document data saved as name, decrypting it when encrypted, and verifying
+// its Integrity, returning it along with its seal and ETag.
+func (s *HTTPStore) getDocument(ctx context.Context, name string) ([]byte, *documentSeal, string, error) {
+	data, eTag, err := s.get(ctx, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	data, err = decode(ctx, s.Cipher, name, data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	data, seal, err := s.Integrity.verify(ctx, s.getURL(name), name, data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return data, seal, eTag, nil
+}
+
+// getHistorySeal returns the seal of the newest history entry from ids, or nil if there is none.
+func (s *HTTPStore) getHistorySeal(ctx context.Context, ids []int) (*documentSeal, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	_, seal, _, err := s.getDocument(ctx, "history/"+getHistoryFileName(ids[len(ids)-1]))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return seal, nil
+}
+
+// SaveState replaces the state only if it was not changed since it was loaded by LoadState.
+func (s *HTTPStore) SaveState(ctx context.Context, state *State, metadata *Metadata) error {
+	stateBytes, err := marshalDocument(state)
+	if err != nil {
+		return err
+	}
+
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return err
+	}
+	historySeal, err := s.getHistorySeal(ctx, ids)
+	if err != nil {
+		return err
+	}
+	sequence := getNextSequence(s.stateSeal, historySeal)
+	historyEntry := &HistoryEntry{Metadata: *metadata, State: state}
+	historyEntry.ID = getNextHistoryID(ids)
+
+	stateBytes, err = s.encodeDocument("state.yaml", stateBytes, sequence, historyEntry.ID)
+	if err != nil {
+		return err
+	}
+
+	header := map[string]string{}
+	if s.stateETag != nil {
+		if *s.stateETag == "" {
+			header["If-None-Match"] = "*"
+		} else {
+			header["If-Match"] = *s.stateETag
+		}
+	}
+	stateETag, err := s.put(ctx, "state.yaml", stateBytes, header)
+	if err != nil {
+		if errors.Is(err, errHTTPPreconditionFailed) {
+			return fmt.Errorf("state was changed by another session since it was loaded: %w", err)
+		}
+		return err
+	}
+	s.stateETag = &stateETag
+	s.stateSeal = &documentSeal{Name: "state.yaml", Sequence: sequence, HistoryID: historyEntry.ID}
+
+	return s.saveHistory(ctx, ids, historyEntry, sequence)
+}
+
+func (s *HTTPStore) LoadState(ctx context.Context) (*State, error) {
+	stateBytes, stateSeal, stateETag, err := s.getDocument(ctx, "state.yaml")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.stateETag = new(string)
+			s.stateSeal = nil
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	historySeal, err := s.getHistorySeal(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Integrity.verifyNotReplayed(ctx, s.getURL("state.yaml"), stateSeal, historySeal); err != nil {
+		return nil, err
+	}
+	var state State
+	if err := unmarshalDocument(stateBytes, stateDocument, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+	s.stateETag = &stateETag
+	s.stateSeal = stateSeal
+	return &state, nil
+}
+
+func (s *HTTPStore) listHistoryIDs(ctx context.Context) ([]int, error) {
+	httpDirEntries, err := s.list(ctx, "history")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, httpDirEntry := range httpDirEntries {
+		names = append(names, httpDirEntry.Name)
+	}
+	return parseHistoryIDs(names), nil
+}
+
+// saveHistory saves historyEntry, with its ID already set after existing ids, for the apply with
+// sequence, and prunes old entries.
+func (s *HTTPStore) saveHistory(ctx context.Context, ids []int, historyEntry *HistoryEntry, sequence uint64) error {
+	ids = append(ids, historyEntry.ID)
+
+	historyEntryBytes, err := marshalDocument(historyEntry)
+	if err != nil {
+		return err
+	}
+	historyEntryBytes, err = s.encodeDocument(
+		"history/"+getHistoryFileName(historyEntry.ID), historyEntryBytes, sequence, historyEntry.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.put(
+		ctx, "history/"+getHistoryFileName(historyEntry.ID), historyEntryBytes,
+		map[string]string{"If-None-Match": "*"},
+	); err != nil {
+		return err
+	}
+
+	for _, id := range getPrunedHistoryIDs(ids, s.HistorySize) {
+		if err := s.delete(ctx, "history/"+getHistoryFileName(id), nil); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *HTTPStore) ListHistory(ctx context.Context) ([]*Metadata, error) {
+	ids, err := s.listHistoryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadatas := []*Metadata{}
+	for _, id := range ids {
+		historyEntry, err := s.LoadHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if historyEntry == nil {
+			continue
+		}
+		metadatas = append(metadatas, &historyEntry.Metadata)
+	}
+	return metadatas, nil
+}
+
+func (s *HTTPStore) LoadHistory(ctx context.Context, id int) (*HistoryEntry, error) {
+	historyEntryBytes, _, _, err := s.getDocument(ctx, "history/"+getHistoryFileName(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load history entry %d: %w", id, err)
+	}
+	var historyEntry HistoryEntry
+	if err := unmarshalDocument(historyEntryBytes, historyEntryDocument, &historyEntry); err != nil {
+		return nil, fmt.Errorf("failed to decode history entry %d: %w", id, err)
+	}
+	return &historyEntry, nil
+}
+
+func (s *HTTPStore) ListLogs(ctx context.Context) ([]*LogInfo, error) {
+	httpDirEntries, err := s.list(ctx, "logs")
+	if err != nil {
+		return nil, err
+	}
+	logInfos := []*LogInfo{}
+	for _, httpDirEntry := range httpDirEntries {
+		name, ok := strings.CutSuffix(httpDirEntry.Name, ".gz")
+		if !ok {
+			continue
+		}
+		logInfos = append(logInfos, &LogInfo{
+			Name:    name,
+			Size:    httpDirEntry.Size,
+			ModTime: httpDirEntry.ModTime,
+		})
+	}
+	return logInfos, nil
+}
+
+func (s *HTTPStore) GetLogReadCloser(ctx context.Context, name string) (io.ReadCloser, error) {
+	fileName, err := getLogFileName(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, "logs/"+fileName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	decodingReadCloser, err := newDecodingReadCloser(ctx, s.Cipher, "logs/"+fileName, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log %s: %w", name, err)
+	}
+	gzipReadCloser, err := newGzipReadCloser(decodingReadCloser)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to read log %s: %w", name, err), decodingReadCloser.Close())
+	}
+	return gzipReadCloser, nil
+}
+
+func (s *HTTPStore) deleteOldLogs(ctx context.Context) error {
+	logInfos, err := s.ListLogs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, logInfo := range s.LogRetention.filter(logInfos, time.Now()) {
+		fileName, err := getLogFileName(logInfo.Name)
+		if err != nil {
+			return err
+		}
+		if err := s.delete(ctx, "logs/"+fileName, nil); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// httpLogWriteCloser buffers a gzip compressed session log in memory, and uploads it on Close.
+type httpLogWriteCloser struct {
+	*gzipWriteCloser
+	ctx      context.Context
+	store    *HTTPStore
+	fileName string
+	buffer   *bytes.Buffer
+}
+
+func (wc *httpLogWriteCloser) Close() error {
+	if err := wc.gzipWriteCloser.Close(); err != nil {
+		return err
+	}
+	_, err := wc.store.put(wc.ctx, "logs/"+wc.fileName, wc.buffer.Bytes(), nil)
+	return err
+}
+
+// GetLogWriterCloser returns a log writer which buffers the log in memory, and uploads it when
+// closed.
+func (s *HTTPStore) GetLogWriterCloser(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := s.deleteOldLogs(ctx); err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	fileName := newLogFileName(name)
+	encodingWriter := newEncodingWriter(s.Cipher, "logs/"+fileName, buffer)
+	gzipWriter, err := gzip.NewWriterLevel(encodingWriter, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	return &httpLogWriteCloser{
+		gzipWriteCloser: &gzipWriteCloser{Writer: gzipWriter, writeCloser: encodingWriter},
+		ctx:             ctx,
+		store:           s,
+		fileName:        fileName,
+		buffer:          buffer,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+)
+
+func TestHTTPStore(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(NewHTTPStoreHandler(dir))
+	t.Cleanup(server.Close)
+
+	store, err := NewHTTPStore(server.URL, "foo/bar")
+	require.NoError(t, err)
+
+	testStore(t, store)
+
+	_, err = os.Stat(filepath.Join(dir, "foo_bar", "state.yaml"))
+	require.NoError(t, err)
+}
+
+func TestHTTPStoreConcurrentSaveState(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	server := httptest.NewServer(NewHTTPStoreHandler(t.TempDir()))
+	t.Cleanup(server.Close)
+
+	store1, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+	store2, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+
+	state := &State{Blueprint: &blueprintPkg.Blueprint{}}
+	metadata := &Metadata{Changed: []string{}}
+
+	_, err = store1.LoadState(ctx)
+	require.NoError(t, err)
+	_, err = store2.LoadState(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, store1.SaveState(ctx, state, metadata))
+	require.ErrorContains(t, store2.SaveState(ctx, state, metadata), "state was changed by another session")
+
+	_, err = store2.LoadState(ctx)
+	require.NoError(t, err)
+	state = &State{Blueprint: &blueprintPkg.Blueprint{}, PreExisting: &blueprintPkg.Blueprint{}}
+	require.NoError(t, store2.SaveState(ctx, state, metadata))
+	require.ErrorContains(t, store1.SaveState(ctx, state, metadata), "state was changed by another session")
+}
+
+func TestHTTPStoreUnlockTakenOver(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	server := httptest.NewServer(NewHTTPStoreHandler(t.TempDir()))
+	t.Cleanup(server.Close)
+
+	store1, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+	store2, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+
+	require.NoError(t, store1.Lock(ctx))
+	require.NoError(t, store2.ForceUnlock(ctx))
+	require.NoError(t, store2.Lock(ctx))
+	require.ErrorContains(t, store1.Unlock(ctx), "lock was taken over by another session")
+	require.NoError(t, store2.Unlock(ctx))
+}
+
+func TestHTTPStoreHistorySize(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	server := httptest.NewServer(NewHTTPStoreHandler(t.TempDir()))
+	t.Cleanup(server.Close)
+
+	store, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+
+	state := &State{Blueprint: &blueprintPkg.Blueprint{}}
+	for _, historySize := range []int{2, 2, 2, 0, -1} {
+		store.HistorySize = historySize
+		_, err := store.LoadState(ctx)
+		require.NoError(t, err)
+		require.NoError(t, store.SaveState(ctx, state, &Metadata{Changed: []string{}}))
+	}
+
+	metadatas, err := store.ListHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, metadatas, 1)
+	require.Equal(t, 5, metadatas[0].ID)
+}
+
+func TestHTTPStoreEncrypted(t *testing.T) {
+	server := httptest.NewServer(NewHTTPStoreHandler(t.TempDir()))
+	t.Cleanup(server.Close)
+
+	store, err := NewHTTPStore(server.URL, "foo")
+	require.NoError(t, err)
+	store.Cipher = newTestCipher(t, 1)
+
+	testStore(t, store)
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpDirEntry is a file listed by HTTPStoreHandler when a directory is requested.
+type httpDirEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func getHTTPETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+}
+
+// HTTPStoreHandler is a http.Handler which serves the API used by HTTPStore, backed by files
+// at a local directory:
+//   - GET of a path ending with / lists all files at the directory as JSON.
+//   - GET of a file returns its contents, with an ETag.
+//   - PUT of a file replaces its contents, creating it if required.
+//   - DELETE of a file removes it.
+//
+// PUT and DELETE honour If-Match and If-None-Match: * headers, so clients can do optimistic
+// concurrency.
+type HTTPStoreHandler struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHTTPStoreHandler creates a new HTTPStoreHandler serving files at path.
+func NewHTTPStoreHandler(path string) *HTTPStoreHandler {
+	return &HTTPStoreHandler{
+		path: path,
+	}
+}
+
+func (h *HTTPStoreHandler) getFilePath(urlPath string) string {
+	return filepath.Join(h.path, filepath.FromSlash(path.Clean("/"+urlPath)))
+}
+
+func (h *HTTPStoreHandler) list(w http.ResponseWriter, r *http.Request) {
+	dirEntries, err := os.ReadDir(h.getFilePath(r.URL.Path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	httpDirEntries := []httpDirEntry{}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		fileInfo, err := dirEntry.Info()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		httpDirEntries = append(httpDirEntries, httpDirEntry{
+			Name:    fileInfo.Name(),
+			Size:    fileInfo.Size(),
+			ModTime: fileInfo.ModTime().UTC(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(httpDirEntries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// read returns the contents of the file at path, or nil if it does not exist.
+func (h *HTTPStoreHandler) read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkPreconditions returns whether If-Match and If-None-Match headers are satisfied by the
+// current file contents, which is nil if it does not exist.
+func checkPreconditions(r *http.Request, data []byte) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if data == nil {
+			return false
+		}
+		if ifMatch != "*" && ifMatch != getHTTPETag(data) {
+			return false
+		}
+	}
+	if r.Header.Get("If-None-Match") == "*" && data != nil {
+		return false
+	}
+	return true
+}
+
+func (h *HTTPStoreHandler) get(w http.ResponseWriter, r *http.Request) {
+	data, err := h.read(h.getFilePath(r.URL.Path))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", getHTTPETag(data))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (h *HTTPStoreHandler) put(w http.ResponseWriter, r *http.Request) {
+	newData, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	filePath := h.getFilePath(r.URL.Path)
+	data, err := h.read(filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !checkPreconditions(r, data) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(newData); err != nil {
+		tmpFile.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpFile.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", getHTTPETag(newData))
+	if data == nil {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *HTTPStoreHandler) delete(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	filePath := h.getFilePath(r.URL.Path)
+	data, err := h.read(filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkPreconditions(r, data) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HTTPStoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if strings.HasSuffix(r.URL.Path, "/") {
+			h.list(w, r)
+		} else {
+			h.get(w, r)
+		}
+	case http.MethodPut, http.MethodDelete:
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.Error(w, "can not change a directory", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			h.put(w, r)
+		} else {
+			h.delete(w, r)
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
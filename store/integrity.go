@@ -0,0 +1,261 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+// integritySchemaVersion is the first schema version which has the checksum recorded at
+// documents, so it is required from it onwards.
+const integritySchemaVersion = 3
+
+// checksumPrefix, namePrefix, sequencePrefix, historyIDPrefix and signaturePrefix prefix the YAML
+// comment lines appended to documents holding their checksum, seal and signature.
+var checksumPrefix = []byte("# resonance-checksum: ")
+var namePrefix = []byte("# resonance-name: ")
+var sequencePrefix = []byte("# resonance-sequence: ")
+var historyIDPrefix = []byte("# resonance-history-id: ")
+var signaturePrefix = []byte("# resonance-signature: ")
+
+// SigningKeySize is the size of signing keys, in bytes.
+const SigningKeySize = ed25519.SeedSize
+
+// ParseSigningKey parses a base64 encoded ed25519 private key seed, eg: as generated with
+// `head -c 32 /dev/urandom | base64`.
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("signing key must be base64 encoded: %w", err)
+	}
+	if len(seed) != SigningKeySize {
+		return nil, fmt.Errorf("signing key must have %d bytes, got %d", SigningKeySize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// IntegrityError is returned when a saved document fails integrity verification, meaning that it
+// was modified outside resonance.
+type IntegrityError struct {
+	Path   string
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf(
+		"%s failed integrity verification: %s: it was modified outside resonance. "+
+			"Review the host for unauthorized changes and restore the file from a backup; or, if "+
+			"the change is trusted, run with --store-skip-verification to accept it, and state is "+
+			"sealed again on the next apply",
+		e.Path, e.Reason,
+	)
+}
+
+// Integrity configures integrity protection of saved documents: all documents have a checksum
+// appended, and are verified when loaded. The checksum and signature cover the document's
+// documentSeal, so that documents can not be moved to another path, and an older state can not
+// be put in place of a newer one.
+type Integrity struct {
+	// SigningKey, when set, is used to sign saved documents, and loaded documents are required to
+	// have a valid signature from it.
+	SigningKey ed25519.PrivateKey
+	// SkipVerification makes verification failures to be logged, instead of returned as errors.
+	SkipVerification bool
+}
+
+// documentSeal binds a document to where and when it was saved, so that a document can not be
+// replayed at another path, nor in place of a newer one. It is covered by the checksum and
+// signature of the document.
+type documentSeal struct {
+	// Name of the document, relative to the store.
+	Name string
+	// Sequence increases with each apply saved to the store, and is never reused, even when
+	// history entries are pruned. It is 0 for documents sealed by older versions.
+	Sequence uint64
+	// HistoryID is the ID of the history entry saved by the same apply.
+	HistoryID int
+}
+
+// getNextSequence returns the sequence for the next apply, after all given seals, which may be
+// nil.
+func getNextSequence(seals ...*documentSeal) uint64 {
+	var sequence uint64
+	for _, seal := range seals {
+		if seal != nil {
+			sequence = max(sequence, seal.Sequence)
+		}
+	}
+	return sequence + 1
+}
+
+// getSealedData returns the data which is checksummed and signed for document data.
+func (d documentSeal) getSealedData(data []byte) []byte {
+	if d.Sequence == 0 {
+		return data
+	}
+	return append([]byte(fmt.Sprintf("%s\n%d\n%d\n", d.Name, d.Sequence, d.HistoryID)), data...)
+}
+
+// seal returns document data with its checksum, seal, and signature when SigningKey is
+// set, appended as YAML comments.
+func (i Integrity) seal(seal documentSeal, data []byte) []byte {
+	sealedData := seal.getSealedData(data)
+	sealed := append([]byte{}, data...)
+	sealed = append(sealed, checksumPrefix...)
+	sealed = append(sealed, fmt.Sprintf("sha256:%x\n", sha256.Sum256(sealedData))...)
+	sealed = append(sealed, namePrefix...)
+	sealed = append(sealed, seal.Name...)
+	sealed = append(sealed, '\n')
+	sealed = append(sealed, sequencePrefix...)
+	sealed = append(sealed, fmt.Sprintf("%d\n", seal.Sequence)...)
+	sealed = append(sealed, historyIDPrefix...)
+	sealed = append(sealed, fmt.Sprintf("%d\n", seal.HistoryID)...)
+	if i.SigningKey != nil {
+		sealed = append(sealed, signaturePrefix...)
+		sealed = append(sealed, base64.StdEncoding.EncodeToString(ed25519.Sign(i.SigningKey, sealedData))...)
+		sealed = append(sealed, '\n')
+	}
+	return sealed
+}
+
+// sealTrailer holds the YAML comments appended to a document by seal.
+type sealTrailer struct {
+	Checksum     string
+	Signature    string
+	DocumentSeal documentSeal
+}
+
+// splitSealed splits data sealed by seal into the document data and its trailer, which is nil
+// if data was not sealed. Documents sealed by older versions have no documentSeal, and have it
+// returned with name and 0 sequence.
+func splitSealed(name string, data []byte) ([]byte, *sealTrailer, error) {
+	index := bytes.LastIndex(data, append([]byte("\n"), checksumPrefix...))
+	if index >= 0 {
+		index++
+	} else if bytes.HasPrefix(data, checksumPrefix) {
+		index = 0
+	} else {
+		return data, nil, nil
+	}
+	document, trailerStr := data[:index], string(data[index:])
+	trailer := &sealTrailer{DocumentSeal: documentSeal{Name: name}}
+	for _, line := range strings.Split(strings.TrimSuffix(trailerStr, "\n"), "\n") {
+		if value, ok := strings.CutPrefix(line, string(checksumPrefix)); ok {
+			trailer.Checksum = value
+		} else if value, ok := strings.CutPrefix(line, string(namePrefix)); ok {
+			trailer.DocumentSeal.Name = value
+		} else if value, ok := strings.CutPrefix(line, string(sequencePrefix)); ok {
+			sequence, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return document, nil, fmt.Errorf("invalid sequence: %w", err)
+			}
+			trailer.DocumentSeal.Sequence = sequence
+		} else if value, ok := strings.CutPrefix(line, string(historyIDPrefix)); ok {
+			historyID, err := strconv.Atoi(value)
+			if err != nil {
+				return document, nil, fmt.Errorf("invalid history ID: %w", err)
+			}
+			trailer.DocumentSeal.HistoryID = historyID
+		} else if value, ok := strings.CutPrefix(line, string(signaturePrefix)); ok {
+			trailer.Signature = value
+		}
+	}
+	return document, trailer, nil
+}
+
+// getUnsealedSchemaVersion returns the schema version of unsealed document data.
+func getUnsealedSchemaVersion(data []byte) int {
+	var document struct {
+		SchemaVersion int `yaml:"schema_version"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil || document.SchemaVersion == 0 {
+		return 1
+	}
+	return document.SchemaVersion
+}
+
+// verifyReason verifies data sealed by seal as document name, returning the document data, its
+// seal, and the reason when verification fails.
+func (i Integrity) verifyReason(name string, data []byte) ([]byte, *documentSeal, string) {
+	document, trailer, err := splitSealed(name, data)
+	if err != nil {
+		return document, &documentSeal{Name: name}, err.Error()
+	}
+	if trailer == nil {
+		seal := &documentSeal{Name: name}
+		if i.SigningKey != nil {
+			return document, seal, "signature is missing"
+		}
+		if getUnsealedSchemaVersion(document) >= integritySchemaVersion {
+			return document, seal, "checksum is missing"
+		}
+		return document, seal, ""
+	}
+	seal := &trailer.DocumentSeal
+	sealedData := seal.getSealedData(document)
+	if trailer.Checksum != fmt.Sprintf("sha256:%x", sha256.Sum256(sealedData)) {
+		return document, seal, "checksum mismatch"
+	}
+	if i.SigningKey != nil {
+		if trailer.Signature == "" {
+			return document, seal, "signature is missing"
+		}
+		signatureBytes, err := base64.StdEncoding.DecodeString(trailer.Signature)
+		if err != nil || !ed25519.Verify(i.SigningKey.Public().(ed25519.PublicKey), sealedData, signatureBytes) {
+			return document, seal, "signature is invalid"
+		}
+	}
+	if seal.Name != name {
+		return document, seal, fmt.Sprintf("it was saved as %s", seal.Name)
+	}
+	return document, seal, ""
+}
+
+// fail returns *IntegrityError for path failing verification with reason, unless
+// SkipVerification is set, when it is logged instead.
+func (i Integrity) fail(ctx context.Context, path, reason string) error {
+	if i.SkipVerification {
+		logger := log.MustLogger(ctx)
+		logger.Warn("Skipping failed integrity verification", "path", path, "reason", reason)
+		return nil
+	}
+	return &IntegrityError{Path: path, Reason: reason}
+}
+
+// verify verifies data sealed by seal as document name, read from path, returning the document
+// data and its seal. When it fails, *IntegrityError is returned, unless SkipVerification is set.
+func (i Integrity) verify(ctx context.Context, path, name string, data []byte) ([]byte, *documentSeal, error) {
+	document, seal, reason := i.verifyReason(name, data)
+	if reason == "" {
+		return document, seal, nil
+	}
+	if err := i.fail(ctx, path, reason); err != nil {
+		return nil, nil, err
+	}
+	return document, seal, nil
+}
+
+// verifyNotReplayed verifies that the state at path, with stateSeal, is not older than the newest
+// history entry, with historySeal, which may be nil, as it is when an older state is put in
+// place of the newer one. When it fails, *IntegrityError is returned, unless SkipVerification is
+// set.
+//
+// Replacing the whole store with an older copy can not be detected.
+func (i Integrity) verifyNotReplayed(ctx context.Context, path string, stateSeal, historySeal *documentSeal) error {
+	if historySeal == nil || stateSeal.Sequence >= historySeal.Sequence {
+		return nil
+	}
+	return i.fail(ctx, path, fmt.Sprintf(
+		"its sequence %d is older than %d from history entry %d, so an older state was put in place",
+		stateSeal.Sequence, historySeal.Sequence, historySeal.HistoryID,
+	))
+}
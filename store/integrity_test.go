@@ -0,0 +1,130 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+)
+
+func newTestIntegrity(t *testing.T, b byte) Integrity {
+	signingKey, err := ParseSigningKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, SigningKeySize)))
+	require.NoError(t, err)
+	return Integrity{SigningKey: signingKey}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	_, err := ParseSigningKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, SigningKeySize)))
+	require.NoError(t, err)
+
+	_, err = ParseSigningKey("not base64!")
+	require.ErrorContains(t, err, "must be base64 encoded")
+
+	_, err = ParseSigningKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.ErrorContains(t, err, "must have 32 bytes, got 5")
+}
+
+func TestIntegrity(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	document := []byte("schema_version: 3\nfoo: bar\n")
+	seal := documentSeal{Name: "test.yaml", Sequence: 2, HistoryID: 1}
+
+	requireIntegrityError := func(t *testing.T, integrity Integrity, data []byte, reason string) {
+		_, _, err := integrity.verify(ctx, "path/test.yaml", "test.yaml", data)
+		var integrityError *IntegrityError
+		require.ErrorAs(t, err, &integrityError)
+		require.Equal(t, "path/test.yaml", integrityError.Path)
+		require.Equal(t, reason, integrityError.Reason)
+		require.ErrorContains(t, err, "--store-skip-verification")
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		integrity := Integrity{}
+		sealed := integrity.seal(seal, document)
+		require.Contains(t, string(sealed), "# resonance-checksum: sha256:")
+
+		verified, verifiedDocumentSeal, err := integrity.verify(ctx, "path/test.yaml", "test.yaml", sealed)
+		require.NoError(t, err)
+		require.Equal(t, document, verified)
+		require.Equal(t, &seal, verifiedDocumentSeal)
+
+		requireIntegrityError(t, integrity, bytes.Replace(sealed, []byte("bar"), []byte("baz"), 1), "checksum mismatch")
+		requireIntegrityError(t, integrity, document, "checksum is missing")
+	})
+
+	t.Run("unsealed legacy", func(t *testing.T) {
+		legacyDocument := []byte("schema_version: 2\nfoo: bar\n")
+		verified, verifiedDocumentSeal, err := Integrity{}.verify(ctx, "path/test.yaml", "test.yaml", legacyDocument)
+		require.NoError(t, err)
+		require.Equal(t, legacyDocument, verified)
+		require.Equal(t, &documentSeal{Name: "test.yaml"}, verifiedDocumentSeal)
+
+		requireIntegrityError(t, newTestIntegrity(t, 1), legacyDocument, "signature is missing")
+	})
+
+	t.Run("signature", func(t *testing.T) {
+		integrity := newTestIntegrity(t, 1)
+		sealed := integrity.seal(seal, document)
+		require.Contains(t, string(sealed), "# resonance-signature: ")
+
+		verified, _, err := integrity.verify(ctx, "path/test.yaml", "test.yaml", sealed)
+		require.NoError(t, err)
+		require.Equal(t, document, verified)
+
+		requireIntegrityError(t, newTestIntegrity(t, 2), sealed, "signature is invalid")
+		requireIntegrityError(t, integrity, Integrity{}.seal(seal, document), "signature is missing")
+
+		signatureLine := bytes.SplitAfter(sealed, []byte("\n"))[6]
+		require.True(t, bytes.HasPrefix(signatureLine, signaturePrefix))
+
+		// Checksum is recomputed, but can not be signed without the key
+		tampered := []byte("schema_version: 3\nfoo: baz\n")
+		requireIntegrityError(t, integrity, append(
+			Integrity{}.seal(seal, tampered), signatureLine...,
+		), "signature is invalid")
+
+		// Signature is bound to the sequence and history ID
+		replayedDocumentSeal := seal
+		replayedDocumentSeal.Sequence = 3
+		requireIntegrityError(t, integrity, append(
+			Integrity{}.seal(replayedDocumentSeal, document), signatureLine...,
+		), "signature is invalid")
+		replayedDocumentSeal = seal
+		replayedDocumentSeal.HistoryID = 2
+		requireIntegrityError(t, integrity, append(
+			Integrity{}.seal(replayedDocumentSeal, document), signatureLine...,
+		), "signature is invalid")
+	})
+
+	t.Run("name", func(t *testing.T) {
+		integrity := newTestIntegrity(t, 1)
+		otherDocumentSeal := seal
+		otherDocumentSeal.Name = "other.yaml"
+		requireIntegrityError(t, integrity, integrity.seal(otherDocumentSeal, document), "it was saved as other.yaml")
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		integrity := Integrity{}
+		require.NoError(t, integrity.verifyNotReplayed(ctx, "path/test.yaml", &seal, nil))
+		require.NoError(t, integrity.verifyNotReplayed(ctx, "path/test.yaml", &seal, &seal))
+
+		historyDocumentSeal := &documentSeal{Name: "history.yaml", Sequence: 3, HistoryID: 2}
+		err := integrity.verifyNotReplayed(ctx, "path/test.yaml", &seal, historyDocumentSeal)
+		var integrityError *IntegrityError
+		require.ErrorAs(t, err, &integrityError)
+		require.Equal(t, "its sequence 2 is older than 3 from history entry 2, so an older state was put in place", integrityError.Reason)
+
+		integrity.SkipVerification = true
+		require.NoError(t, integrity.verifyNotReplayed(ctx, "path/test.yaml", &seal, historyDocumentSeal))
+	})
+
+	t.Run("skip verification", func(t *testing.T) {
+		integrity := Integrity{SkipVerification: true}
+		verified, _, err := integrity.verify(ctx, "path/test.yaml", "test.yaml", document)
+		require.NoError(t, err)
+		require.Equal(t, document, verified)
+	})
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fornellas/slogxt/log"
+
+	"github.com/fornellas/resonance/host/lib"
+	"github.com/fornellas/resonance/host/types"
+)
+
+// machineIDPaths are the paths where the machine-id of a host may be found, in order of
+// preference, as in machine-id(5).
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// machineIDRegexp matches valid machine-ids, as in machine-id(5).
+var machineIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// HostIdentity identifies a host stably across sessions.
+type HostIdentity struct {
+	// Host is the host, as in types.Host.String.
+	Host string `yaml:"host"`
+	// MachineID is the machine-id of the host, or empty, if it does not have one, as it happens
+	// with some containers.
+	MachineID string `yaml:"machine_id"`
+}
+
+// GetHostIdentity returns the HostIdentity of host. Invalid machine-ids are ignored.
+func GetHostIdentity(ctx context.Context, host types.Host) (*HostIdentity, error) {
+	hostIdentity := &HostIdentity{
+		Host: host.String(),
+	}
+	for _, path := range machineIDPaths {
+		readCloser, err := host.ReadFile(ctx, path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read machine-id: %w", err)
+		}
+		machineIDBytes, err := io.ReadAll(readCloser)
+		if err := errors.Join(err, readCloser.Close()); err != nil {
+			return nil, fmt.Errorf("failed to read machine-id: %w", err)
+		}
+		machineID := strings.TrimSpace(string(machineIDBytes))
+		if machineID == "" {
+			continue
+		}
+		if !machineIDRegexp.MatchString(machineID) {
+			logger := log.MustLogger(ctx)
+			logger.Warn("Ignoring invalid machine-id", "path", path, "machine_id", machineID)
+			continue
+		}
+		hostIdentity.MachineID = machineID
+		break
+	}
+	return hostIdentity, nil
+}
+
+func (i *HostIdentity) String() string {
+	if i.MachineID == "" {
+		return i.Host
+	}
+	return fmt.Sprintf("%s (%s)", i.Host, i.MachineID)
+}
+
+// dirNameUnsafeRegexp matches characters which are replaced at directory names.
+var dirNameUnsafeRegexp = regexp.MustCompile(`[^A-Za-z0-9._@:+-]`)
+
+// getDirNamePart returns value with characters unsafe for directory names replaced.
+func getDirNamePart(value string) (string, error) {
+	part := dirNameUnsafeRegexp.ReplaceAllString(value, "_")
+	if part == "" || part == "." || part == ".." {
+		return "", fmt.Errorf("invalid directory name: %#v", value)
+	}
+	return part, nil
+}
+
+// dirName returns the name of the directory for the host at LocalStore.
+func (i *HostIdentity) dirName() (string, error) {
+	dirName, err := getDirNamePart(i.Host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host: %w", err)
+	}
+	if i.MachineID != "" {
+		machineIDPart, err := getDirNamePart(i.MachineID)
+		if err != nil {
+			return "", fmt.Errorf("invalid machine-id: %w", err)
+		}
+		dirName += "_" + machineIDPart
+	}
+	return dirName, nil
+}
+
+// hostIdentityFileName is the name of the file at each host directory of LocalStore which holds
+// its HostIdentity.
+const hostIdentityFileName = "host.yaml"
+
+// LocalStore is a HostStore at a directory with a sub directory per HostIdentity, so that a single
+// directory can hold state and logs for multiple hosts.
+type LocalStore struct {
+	*HostStore
+	hostIdentity *HostIdentity
+	hostPath     string
+}
+
+// NewLocalStore creates a new LocalStore at path on given host, storing state for the host with
+// hostIdentity.
+func NewLocalStore(ctx context.Context, host types.Host, path string, hostIdentity *HostIdentity) (*LocalStore, error) {
+	dirName, err := hostIdentity.dirName()
+	if err != nil {
+		return nil, err
+	}
+	hostPath := filepath.Join(path, dirName)
+
+	legacyStatePath := filepath.Join(path, "state", "v1")
+	if _, err := host.Lstat(ctx, legacyStatePath); err == nil {
+		logger := log.MustLogger(ctx)
+		logger.Warn(
+			"Found state from before the local store was namespaced per host, which is ignored: "+
+				"if it belongs to this host, move it to the host directory",
+			"path", legacyStatePath, "host_directory", hostPath,
+		)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return &LocalStore{
+		HostStore:    NewHostStore(host, hostPath),
+		hostIdentity: hostIdentity,
+		hostPath:     hostPath,
+	}, nil
+}
+
+// saveHostIdentity saves the HostIdentity at the host directory, so that it can be listed by
+// ListLocalStoreHosts.
+func (s *LocalStore) saveHostIdentity(ctx context.Context) error {
+	hostIdentityBytes, err := yaml.Marshal(s.hostIdentity)
+	if err != nil {
+		return err
+	}
+	if err := lib.MkdirAll(ctx, s.Host, s.hostPath, 0700); err != nil {
+		return err
+	}
+	return s.Host.WriteFile(
+		ctx, filepath.Join(s.hostPath, hostIdentityFileName), bytes.NewReader(hostIdentityBytes), 0600,
+	)
+}
+
+func (s *LocalStore) SaveState(ctx context.Context, state *State, metadata *Metadata) error {
+	if err := s.saveHostIdentity(ctx); err != nil {
+		return err
+	}
+	return s.HostStore.SaveState(ctx, state, metadata)
+}
+
+func (s *LocalStore) GetLogWriterCloser(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := s.saveHostIdentity(ctx); err != nil {
+		return nil, err
+	}
+	return s.HostStore.GetLogWriterCloser(ctx, name)
+}
+
+// ListLocalStoreHosts returns the HostIdentity of all hosts with state or logs at the LocalStore at
+// path on given host, sorted.
+func ListLocalStoreHosts(ctx context.Context, host types.Host, path string) ([]*HostIdentity, error) {
+	dirEntResultCh, cancel := host.ReadDir(ctx, path)
+	defer cancel()
+
+	names := []string{}
+	for dirEntResult := range dirEntResultCh {
+		if dirEntResult.Error != nil {
+			if errors.Is(dirEntResult.Error, os.ErrNotExist) {
+				return []*HostIdentity{}, nil
+			}
+			return nil, dirEntResult.Error
+		}
+		if dirEntResult.DirEnt.IsDirectory() {
+			names = append(names, dirEntResult.DirEnt.Name)
+		}
+	}
+	sort.Strings(names)
+
+	hostIdentities := []*HostIdentity{}
+	for _, name := range names {
+		hostIdentityPath := filepath.Join(path, name, hostIdentityFileName)
+		readCloser, err := host.ReadFile(ctx, hostIdentityPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		hostIdentityBytes, err := io.ReadAll(readCloser)
+		if err := errors.Join(err, readCloser.Close()); err != nil {
+			return nil, err
+		}
+		var hostIdentity HostIdentity
+		if err := decodeYAML(hostIdentityBytes, &hostIdentity); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", hostIdentityPath, err)
+		}
+		hostIdentities = append(hostIdentities, &hostIdentity)
+	}
+	return hostIdentities, nil
+}
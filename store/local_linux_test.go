@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fornellas/slogxt/log"
+
+	hostPkg "github.com/fornellas/resonance/host"
+	"github.com/fornellas/resonance/host/types"
+)
+
+// machineIDHost is a host with machine-id files with given contents.
+type machineIDHost struct {
+	types.Host
+	machineIDs map[string]string
+}
+
+func (h machineIDHost) ReadFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	machineID, ok := h.machineIDs[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(machineID)), nil
+}
+
+func TestGetHostIdentity(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	machineID := "0123456789abcdef0123456789abcdef"
+
+	for _, tc := range []struct {
+		name              string
+		machineIDs        map[string]string
+		expectedMachineID string
+	}{
+		{"valid", map[string]string{"/etc/machine-id": machineID + "\n"}, machineID},
+		{"dbus", map[string]string{"/var/lib/dbus/machine-id": machineID + "\n"}, machineID},
+		{"none", map[string]string{}, ""},
+		{"empty", map[string]string{"/etc/machine-id": "\n"}, ""},
+		{"invalid", map[string]string{"/etc/machine-id": "../../etc\n"}, ""},
+		{"uppercase", map[string]string{"/etc/machine-id": strings.ToUpper(machineID)}, ""},
+		{"short", map[string]string{"/etc/machine-id": machineID[1:]}, ""},
+		{"invalid then dbus", map[string]string{
+			"/etc/machine-id":          "uninitialized\n",
+			"/var/lib/dbus/machine-id": machineID + "\n",
+		}, machineID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hostIdentity, err := GetHostIdentity(ctx, machineIDHost{Host: hostPkg.Local{}, machineIDs: tc.machineIDs})
+			require.NoError(t, err)
+			require.Equal(t, &HostIdentity{Host: "localhost", MachineID: tc.expectedMachineID}, hostIdentity)
+		})
+	}
+}
+
+func TestHostIdentityDirName(t *testing.T) {
+	for _, tc := range []struct {
+		hostIdentity    HostIdentity
+		expectedDirName string
+		expectedError   string
+	}{
+		{HostIdentity{Host: "localhost"}, "localhost", ""},
+		{HostIdentity{Host: "user@host:22", MachineID: "0123456789abcdef"}, "user@host:22_0123456789abcdef", ""},
+		{HostIdentity{Host: "/path/to/chroot"}, "_path_to_chroot", ""},
+		{HostIdentity{Host: "host", MachineID: "../x"}, "host_.._x", ""},
+		{HostIdentity{Host: "a\nb c"}, "a_b_c", ""},
+		{HostIdentity{Host: ""}, "", `invalid host: invalid directory name: ""`},
+		{HostIdentity{Host: "."}, "", `invalid host: invalid directory name: "."`},
+		{HostIdentity{Host: ".."}, "", `invalid host: invalid directory name: ".."`},
+		{HostIdentity{Host: "host", MachineID: ".."}, "", `invalid machine-id: invalid directory name: ".."`},
+	} {
+		t.Run(tc.hostIdentity.String(), func(t *testing.T) {
+			dirName, err := tc.hostIdentity.dirName()
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDirName, dirName)
+		})
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := log.WithTestLogger(t.Context())
+	host := hostPkg.Local{}
+	path := t.TempDir()
+
+	hostIdentity, err := GetHostIdentity(ctx, host)
+	require.NoError(t, err)
+	require.Equal(t, "localhost", hostIdentity.Host)
+
+	store, err := NewLocalStore(ctx, host, path, hostIdentity)
+	require.NoError(t, err)
+
+	testStore(t, store)
+
+	otherHostIdentity := &HostIdentity{Host: "user@other/host", MachineID: "0123456789abcdef"}
+	otherStore, err := NewLocalStore(ctx, host, path, otherHostIdentity)
+	require.NoError(t, err)
+
+	state, err := otherStore.LoadState(ctx)
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	logWriterCloser, err := otherStore.GetLogWriterCloser(ctx, "test")
+	require.NoError(t, err)
+	require.NoError(t, logWriterCloser.Close())
+
+	logInfos, err := store.ListLogs(ctx)
+	require.NoError(t, err)
+	require.Len(t, logInfos, 1)
+
+	hostIdentities, err := ListLocalStoreHosts(ctx, host, path)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []*HostIdentity{hostIdentity, otherHostIdentity}, hostIdentities)
+
+	hostIdentities, err = ListLocalStoreHosts(ctx, host, t.TempDir()+"/non-existent")
+	require.NoError(t, err)
+	require.Empty(t, hostIdentities)
+
+	_, err = NewLocalStore(ctx, host, path, &HostIdentity{Host: ".."})
+	require.ErrorContains(t, err, "invalid host")
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"syscall"
+	"time"
+)
+
+// LockInfo identifies the holder of a store lock.
+type LockInfo struct {
+	Hostname  string    `yaml:"hostname"`
+	User      string    `yaml:"user"`
+	PID       int       `yaml:"pid"`
+	StartTime time.Time `yaml:"start_time"`
+}
+
+// NewLockInfo returns a LockInfo for the current process.
+func NewLockInfo() (*LockInfo, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	u, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return &LockInfo{
+		Hostname:  hostname,
+		User:      u.Username,
+		PID:       os.Getpid(),
+		StartTime: time.Now().UTC(),
+	}, nil
+}
+
+func (l *LockInfo) String() string {
+	return fmt.Sprintf(
+		"%s@%s PID %d since %s", l.User, l.Hostname, l.PID, l.StartTime.Format(time.RFC3339),
+	)
+}
+
+// Equal returns true when both identify the same lock holder.
+func (l *LockInfo) Equal(other *LockInfo) bool {
+	return l.Hostname == other.Hostname &&
+		l.User == other.User &&
+		l.PID == other.PID &&
+		l.StartTime.Equal(other.StartTime)
+}
+
+// IsStale returns true when the lock holder is known to be gone: it was started at the same
+// hostname as the current process, but its PID is not running anymore.
+func (l *LockInfo) IsStale() (bool, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	if l.Hostname != hostname {
+		return false, nil
+	}
+	if err := syscall.Kill(l.PID, 0); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return true, nil
+		}
+		if errors.Is(err, syscall.EPERM) {
+			return false, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// LockedError is returned when trying to lock a store that is already locked.
+type LockedError struct {
+	// LockInfo of the holder, or nil, when unknown.
+	LockInfo *LockInfo
+}
+
+func (e *LockedError) Error() string {
+	if e.LockInfo == nil {
+		return "store is locked by an unknown holder"
+	}
+	return fmt.Sprintf("store is locked by %s", e.LockInfo)
+}
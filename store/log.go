@@ -0,0 +1,116 @@
+package store
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// LogInfo describes a session log saved at a store.
+type LogInfo struct {
+	// Name of the log, as accepted by Store.GetLogReadCloser.
+	Name string
+	// Size of the compressed log, in bytes.
+	Size int64
+	// ModTime is the last time the log was written to.
+	ModTime time.Time
+}
+
+// LogRetention defines which session logs are kept at a store. A zero value for any of its fields
+// disables the respective limit.
+type LogRetention struct {
+	// Count is the maximum number of logs kept.
+	Count int
+	// MaxAge of logs kept.
+	MaxAge time.Duration
+	// MaxSize is the maximum total size, in bytes, of all logs kept.
+	MaxSize int64
+}
+
+// DefaultLogRetention is the default LogRetention used by stores.
+var DefaultLogRetention = LogRetention{
+	Count: 10,
+}
+
+// filter returns the logs, sorted oldest first, which must be deleted to honour the retention.
+func (r LogRetention) filter(logInfos []*LogInfo, now time.Time) []*LogInfo {
+	toDelete := []*LogInfo{}
+	kept := logInfos
+	if r.Count > 0 && len(kept) > r.Count {
+		toDelete = append(toDelete, kept[:len(kept)-r.Count]...)
+		kept = kept[len(kept)-r.Count:]
+	}
+	if r.MaxAge > 0 {
+		for len(kept) > 0 && now.Sub(kept[0].ModTime) > r.MaxAge {
+			toDelete = append(toDelete, kept[0])
+			kept = kept[1:]
+		}
+	}
+	if r.MaxSize > 0 {
+		var size int64
+		for _, logInfo := range kept {
+			size += logInfo.Size
+		}
+		for len(kept) > 0 && size > r.MaxSize {
+			toDelete = append(toDelete, kept[0])
+			size -= kept[0].Size
+			kept = kept[1:]
+		}
+	}
+	return toDelete
+}
+
+// newLogFileName returns the file name for a new session log with given name.
+func newLogFileName(name string) string {
+	return fmt.Sprintf("%s.%s.gz", time.Now().UTC().Format("20060102150405"), name)
+}
+
+// getLogFileName returns the file name for the session log with given name, as in LogInfo.Name.
+func getLogFileName(name string) (string, error) {
+	name = strings.TrimSuffix(name, ".gz")
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid log name: %#v", name)
+	}
+	return name + ".gz", nil
+}
+
+// gzipWriteCloser compresses to a gzip stream, closing both the gzip.Writer and the underlying
+// io.WriteCloser on Close.
+type gzipWriteCloser struct {
+	*gzip.Writer
+	writeCloser io.WriteCloser
+}
+
+func (w *gzipWriteCloser) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.writeCloser.Close()
+}
+
+// gzipReadCloser decompresses a gzip stream, closing both the gzip.Reader and the underlying
+// io.ReadCloser on Close.
+type gzipReadCloser struct {
+	*gzip.Reader
+	readCloser io.ReadCloser
+}
+
+// newGzipReadCloser returns a gzipReadCloser reading from readCloser, which the caller must close
+// on error.
+func newGzipReadCloser(readCloser io.ReadCloser) (*gzipReadCloser, error) {
+	gzipReader, err := gzip.NewReader(readCloser)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipReadCloser{
+		Reader:     gzipReader,
+		readCloser: readCloser,
+	}, nil
+}
+
+func (r *gzipReadCloser) Close() error {
+	return errors.Join(r.Reader.Close(), r.readCloser.Close())
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRetentionFilter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	logInfos := []*LogInfo{
+		{Name: "1", Size: 10, ModTime: now.Add(-4 * time.Hour)},
+		{Name: "2", Size: 20, ModTime: now.Add(-3 * time.Hour)},
+		{Name: "3", Size: 30, ModTime: now.Add(-2 * time.Hour)},
+		{Name: "4", Size: 40, ModTime: now.Add(-1 * time.Hour)},
+	}
+
+	names := func(logInfos []*LogInfo) []string {
+		names := []string{}
+		for _, logInfo := range logInfos {
+			names = append(names, logInfo.Name)
+		}
+		return names
+	}
+
+	type testCase struct {
+		name          string
+		logRetention  LogRetention
+		expectedNames []string
+	}
+	for _, tc := range []testCase{
+		{
+			name:          "unlimited",
+			logRetention:  LogRetention{},
+			expectedNames: []string{},
+		},
+		{
+			name:          "Count",
+			logRetention:  LogRetention{Count: 3},
+			expectedNames: []string{"1"},
+		},
+		{
+			name:          "MaxAge",
+			logRetention:  LogRetention{MaxAge: 150 * time.Minute},
+			expectedNames: []string{"1", "2"},
+		},
+		{
+			name:          "MaxSize",
+			logRetention:  LogRetention{MaxSize: 40},
+			expectedNames: []string{"1", "2", "3"},
+		},
+		{
+			name:          "all",
+			logRetention:  LogRetention{Count: 3, MaxAge: 200 * time.Minute, MaxSize: 80},
+			expectedNames: []string{"1", "2"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedNames, names(tc.logRetention.filter(logInfos, now)))
+		})
+	}
+}
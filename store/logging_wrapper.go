@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"io"
+
+	"github.com/fornellas/slogxt/log"
 )
 
 // Wraps a Store and log function calls.
@@ -16,6 +18,60 @@ func NewLoggingWrapper(store Store) *LoggingWrapper {
 	}
 }
 
+func (s *LoggingWrapper) Lock(ctx context.Context) error {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("Lock")
+	return s.store.Lock(ctx)
+}
+
+func (s *LoggingWrapper) Unlock(ctx context.Context) error {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("Unlock")
+	return s.store.Unlock(ctx)
+}
+
+func (s *LoggingWrapper) ForceUnlock(ctx context.Context) error {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("ForceUnlock")
+	return s.store.ForceUnlock(ctx)
+}
+
+func (s *LoggingWrapper) SaveState(ctx context.Context, state *State, metadata *Metadata) error {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("SaveState")
+	return s.store.SaveState(ctx, state, metadata)
+}
+
+func (s *LoggingWrapper) LoadState(ctx context.Context) (*State, error) {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("LoadState")
+	return s.store.LoadState(ctx)
+}
+
+func (s *LoggingWrapper) ListHistory(ctx context.Context) ([]*Metadata, error) {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("ListHistory")
+	return s.store.ListHistory(ctx)
+}
+
+func (s *LoggingWrapper) LoadHistory(ctx context.Context, id int) (*HistoryEntry, error) {
+	ctx, logger := log.MustWithGroupAttrs(ctx, "🗃️ Store", "id", id)
+	logger.Debug("LoadHistory")
+	return s.store.LoadHistory(ctx, id)
+}
+
 func (s *LoggingWrapper) GetLogWriterCloser(ctx context.Context, name string) (io.WriteCloser, error) {
 	return s.store.GetLogWriterCloser(ctx, name)
 }
+
+func (s *LoggingWrapper) ListLogs(ctx context.Context) ([]*LogInfo, error) {
+	ctx, logger := log.MustWithGroup(ctx, "🗃️ Store")
+	logger.Debug("ListLogs")
+	return s.store.ListLogs(ctx)
+}
+
+func (s *LoggingWrapper) GetLogReadCloser(ctx context.Context, name string) (io.ReadCloser, error) {
+	ctx, logger := log.MustWithGroupAttrs(ctx, "🗃️ Store", "name", name)
+	logger.Debug("GetLogReadCloser")
+	return s.store.GetLogReadCloser(ctx, name)
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version of the schema of State and HistoryEntry documents saved by stores.
+// Whenever resource or store structs change in a way that previously saved documents can not be
+// decoded anymore, it must be incremented, and a migration from the previous version added to
+// migrations. Versions are:
+//   - 1: documents without a schema version.
+//   - 2: schema version is recorded at documents, and states always have the original state of
+//     resources.
+//   - 3: checksum is recorded at documents, as in Integrity, which documents are otherwise
+//     unchanged for.
+var SchemaVersion = 3
+
+// schemaVersionKey is the key at documents which holds their schema version. Documents without it
+// are from schema version 1.
+const schemaVersionKey = "schema_version"
+
+// SchemaDowngradeError is returned when loading a document saved with a schema version newer than
+// SchemaVersion, as it can not be read without losing information.
+type SchemaDowngradeError struct {
+	Version int
+}
+
+func (e *SchemaDowngradeError) Error() string {
+	return fmt.Sprintf(
+		"saved with schema version %d, but this version of resonance supports up to schema version %d: "+
+			"refusing to downgrade, upgrade resonance instead",
+		e.Version, SchemaVersion,
+	)
+}
+
+// documentKind is the kind of document being migrated.
+type documentKind int
+
+const (
+	stateDocument documentKind = iota
+	historyEntryDocument
+)
+
+// migration migrates documents from a schema version to the next one. Documents are YAML
+// mapping nodes, which are changed in place.
+type migration struct {
+	// state migrates a State, either saved on its own or as part of a HistoryEntry.
+	state func(state *yaml.Node) error
+	// historyEntry migrates a HistoryEntry, except for its State, which is migrated by state.
+	historyEntry func(historyEntry *yaml.Node) error
+}
+
+// migrations holds all migrations, where migrations[i] migrates from schema version i to i+1.
+// Versions where documents are unchanged have none.
+var migrations = map[int]migration{
+	1: {state: migrateOriginal},
+}
+
+// migrateOriginal migrates a State saved before the original state of resources was recorded,
+// setting it, when known, from the state of resources from before the last apply, the oldest known.
+func migrateOriginal(state *yaml.Node) error {
+	if original := getMappingValue(state, "original"); original != nil && original.Tag != "!!null" {
+		return nil
+	}
+	preExisting := getMappingValue(state, "pre_existing")
+	if preExisting == nil {
+		return nil
+	}
+	deleteMappingKey(state, "original")
+	state.Content = append(state.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "original"},
+		preExisting,
+	)
+	return nil
+}
+
+// getMappingValue returns the value for key at mapping node, or nil if it is not set.
+func getMappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// deleteMappingKey removes key from mapping node.
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// getSchemaVersion returns the schema version of document.
+func getSchemaVersion(document *yaml.Node) (int, error) {
+	versionNode := getMappingValue(document, schemaVersionKey)
+	if versionNode == nil {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(versionNode.Value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid %s: %#v", schemaVersionKey, versionNode.Value)
+	}
+	return version, nil
+}
+
+// migrate migrates document of given kind to SchemaVersion, one version at a time, removing its
+// schema version. A *SchemaDowngradeError is returned if document is from a newer schema version.
+func migrate(document *yaml.Node, kind documentKind) error {
+	version, err := getSchemaVersion(document)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return &SchemaDowngradeError{Version: version}
+	}
+	deleteMappingKey(document, schemaVersionKey)
+
+	for ; version < SchemaVersion; version++ {
+		migration := migrations[version]
+		state := document
+		if kind == historyEntryDocument {
+			if migration.historyEntry != nil {
+				if err := migration.historyEntry(document); err != nil {
+					return fmt.Errorf("failed to migrate from schema version %d to %d: %w", version, version+1, err)
+				}
+			}
+			state = getMappingValue(document, "state")
+		}
+		if migration.state != nil && state != nil && state.Kind == yaml.MappingNode {
+			if err := migration.state(state); err != nil {
+				return fmt.Errorf("failed to migrate from schema version %d to %d: %w", version, version+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// marshalDocument marshals v, a State or HistoryEntry, recording SchemaVersion at it.
+func marshalDocument(v any) ([]byte, error) {
+	var document yaml.Node
+	if err := document.Encode(v); err != nil {
+		return nil, err
+	}
+	if document.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("bug: document is not a mapping: %#v", v)
+	}
+	document.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: schemaVersionKey},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(SchemaVersion)},
+	}, document.Content...)
+	return yaml.Marshal(&document)
+}
+
+// unmarshalDocument decodes data, a document of given kind as saved by marshalDocument, into v,
+// migrating it from older schema versions first.
+func unmarshalDocument(data []byte, kind documentKind, v any) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	if node.Kind != yaml.DocumentNode || len(node.Content) != 1 || node.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("expected a YAML mapping")
+	}
+	if err := migrate(node.Content[0], kind); err != nil {
+		return err
+	}
+	migratedData, err := yaml.Marshal(&node)
+	if err != nil {
+		return err
+	}
+	return decodeYAML(migratedData, v)
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/resources"
+)
+
+// setTestMigrations replaces migrations and SchemaVersion with given ones for the duration of the
+// test.
+func setTestMigrations(t *testing.T, testMigrations map[int]migration, schemaVersion int) {
+	originalMigrations := migrations
+	originalSchemaVersion := SchemaVersion
+	t.Cleanup(func() {
+		migrations = originalMigrations
+		SchemaVersion = originalSchemaVersion
+	})
+	migrations = testMigrations
+	SchemaVersion = schemaVersion
+}
+
+// appendStep returns a migration func which appends step to the value of key "steps".
+func appendStep(step string) func(node *yaml.Node) error {
+	return func(node *yaml.Node) error {
+		stepsNode := getMappingValue(node, "steps")
+		if stepsNode == nil {
+			return fmt.Errorf("missing steps")
+		}
+		stepsNode.Value += step
+		return nil
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for version := range migrations {
+		require.GreaterOrEqual(t, version, 1)
+		require.Less(t, version, SchemaVersion)
+	}
+}
+
+func TestMarshalDocument(t *testing.T) {
+	contents := "foo"
+	state := &State{
+		Blueprint: &blueprintPkg.Blueprint{
+			Entries: []*blueprintPkg.Entry{
+				{
+					TypeName: "File",
+					Resource: &resources.File{Path: "/foo", RegularFile: &contents},
+				},
+			},
+		},
+	}
+
+	data, err := marshalDocument(state)
+	require.NoError(t, err)
+	require.Contains(t, string(data), fmt.Sprintf("schema_version: %d\n", SchemaVersion))
+
+	var unmarshaledState State
+	require.NoError(t, unmarshalDocument(data, stateDocument, &unmarshaledState))
+	require.Len(t, unmarshaledState.Blueprint.Entries, 1)
+	require.Equal(t, state.Blueprint.Entries[0].Resource, unmarshaledState.Blueprint.Entries[0].Resource)
+}
+
+func TestMigrate(t *testing.T) {
+	t.Run("steps", func(t *testing.T) {
+		setTestMigrations(t, map[int]migration{
+			1: {state: appendStep("2")},
+			3: {state: appendStep("4"), historyEntry: appendStep("4")},
+		}, 4)
+
+		var state map[string]string
+		require.NoError(t, unmarshalDocument([]byte("steps: \"1\"\n"), stateDocument, &state))
+		require.Equal(t, map[string]string{"steps": "124"}, state)
+
+		require.NoError(t, unmarshalDocument([]byte("schema_version: 3\nsteps: \"3\"\n"), stateDocument, &state))
+		require.Equal(t, map[string]string{"steps": "34"}, state)
+
+		var historyEntry struct {
+			Steps string            `yaml:"steps"`
+			State map[string]string `yaml:"state"`
+		}
+		require.NoError(t, unmarshalDocument(
+			[]byte("steps: \"1\"\nstate:\n  steps: \"1\"\n"), historyEntryDocument, &historyEntry,
+		))
+		require.Equal(t, "14", historyEntry.Steps)
+		require.Equal(t, map[string]string{"steps": "124"}, historyEntry.State)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		setTestMigrations(t, map[int]migration{
+			1: {state: appendStep("2")},
+		}, 2)
+
+		var state map[string]string
+		require.ErrorContains(t, unmarshalDocument(
+			[]byte("foo: bar\n"), stateDocument, &state,
+		), "failed to migrate from schema version 1 to 2: missing steps")
+	})
+
+	t.Run("downgrade", func(t *testing.T) {
+		var state State
+		err := unmarshalDocument(
+			[]byte(fmt.Sprintf("schema_version: %d\n", SchemaVersion+1)), stateDocument, &state,
+		)
+		var schemaDowngradeError *SchemaDowngradeError
+		require.ErrorAs(t, err, &schemaDowngradeError)
+		require.Equal(t, SchemaVersion+1, schemaDowngradeError.Version)
+		require.ErrorContains(t, err, "upgrade resonance")
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		var state State
+		require.ErrorContains(t, unmarshalDocument(
+			[]byte("schema_version: foo\n"), stateDocument, &state,
+		), "invalid schema_version")
+	})
+}
+
+// migrateYAML migrates document of given kind and returns it marshaled.
+func migrateYAML(t *testing.T, document string, kind documentKind) string {
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(document), &node))
+	require.NoError(t, migrate(node.Content[0], kind))
+	data, err := yaml.Marshal(node.Content[0])
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMigrateV1ToV2(t *testing.T) {
+	t.Run("state without original", func(t *testing.T) {
+		require.Equal(t,
+			"blueprint:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        regular_file: bar\n"+
+				"pre_existing:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        regular_file: foo\n"+
+				"original:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        regular_file: foo\n",
+			migrateYAML(t,
+				"blueprint:\n"+
+					"    - File:\n"+
+					"        path: /foo\n"+
+					"        regular_file: bar\n"+
+					"pre_existing:\n"+
+					"    - File:\n"+
+					"        path: /foo\n"+
+					"        regular_file: foo\n",
+				stateDocument,
+			),
+		)
+	})
+
+	t.Run("state with null original", func(t *testing.T) {
+		require.Equal(t,
+			"blueprint: []\n"+
+				"pre_existing:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        absent: true\n"+
+				"original:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        absent: true\n",
+			migrateYAML(t,
+				"blueprint: []\n"+
+					"pre_existing:\n"+
+					"    - File:\n"+
+					"        path: /foo\n"+
+					"        absent: true\n"+
+					"original: null\n",
+				stateDocument,
+			),
+		)
+	})
+
+	t.Run("state with original", func(t *testing.T) {
+		document := "blueprint: []\n" +
+			"pre_existing: null\n" +
+			"original:\n" +
+			"    - File:\n" +
+			"        path: /foo\n" +
+			"        absent: true\n"
+		require.Equal(t, document, migrateYAML(t, document, stateDocument))
+	})
+
+	t.Run("state without pre_existing", func(t *testing.T) {
+		document := "blueprint: []\n"
+		require.Equal(t, document, migrateYAML(t, document, stateDocument))
+	})
+
+	t.Run("history entry", func(t *testing.T) {
+		require.Equal(t,
+			"id: 1\n"+
+				"time: 2024-01-01T00:00:00Z\n"+
+				"version: v1.0.0\n"+
+				"blueprint_checksum: sha256:0\n"+
+				"changed:\n"+
+				"    - File:/foo\n"+
+				"in_sync: 0\n"+
+				"state:\n"+
+				"    blueprint:\n"+
+				"        - File:\n"+
+				"            path: /foo\n"+
+				"            regular_file: foo\n"+
+				"    pre_existing: null\n"+
+				"    original: null\n",
+			migrateYAML(t,
+				"id: 1\n"+
+					"time: 2024-01-01T00:00:00Z\n"+
+					"version: v1.0.0\n"+
+					"blueprint_checksum: sha256:0\n"+
+					"changed:\n"+
+					"    - File:/foo\n"+
+					"in_sync: 0\n"+
+					"state:\n"+
+					"    blueprint:\n"+
+					"        - File:\n"+
+					"            path: /foo\n"+
+					"            regular_file: foo\n"+
+					"    pre_existing: null\n",
+				historyEntryDocument,
+			),
+		)
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		contents := "foo"
+		resource := &resources.File{Path: "/foo", RegularFile: &contents}
+
+		var state State
+		require.NoError(t, unmarshalDocument([]byte(
+			"blueprint: []\n"+
+				"pre_existing:\n"+
+				"    - File:\n"+
+				"        path: /foo\n"+
+				"        regular_file: foo\n",
+		), stateDocument, &state))
+		require.Len(t, state.Original.Entries, 1)
+		require.Equal(t, resource, state.Original.Entries[0].Resource)
+
+		var historyEntry HistoryEntry
+		require.NoError(t, unmarshalDocument([]byte(
+			"id: 1\n"+
+				"time: 2024-01-01T00:00:00Z\n"+
+				"version: v1.0.0\n"+
+				"blueprint_checksum: sha256:0\n"+
+				"changed:\n"+
+				"    - File:/foo\n"+
+				"in_sync: 0\n"+
+				"state:\n"+
+				"    blueprint:\n"+
+				"        - File:\n"+
+				"            path: /foo\n"+
+				"            regular_file: foo\n"+
+				"    pre_existing: null\n",
+		), historyEntryDocument, &historyEntry))
+		require.Equal(t, Metadata{
+			ID:                1,
+			Time:              time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Version:           "v1.0.0",
+			BlueprintChecksum: "sha256:0",
+			Changed:           []string{"File:/foo"},
+		}, historyEntry.Metadata)
+		require.Len(t, historyEntry.State.Blueprint.Entries, 1)
+		require.Equal(t, resource, historyEntry.State.Blueprint.Entries[0].Resource)
+		require.Nil(t, historyEntry.State.Original)
+	})
+}
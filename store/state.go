@@ -0,0 +1,69 @@
+package store
+
+import (
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+)
+
+// State holds the state of a host after a successful apply.
+type State struct {
+	// Blueprint is the last successfully applied blueprint.
+	Blueprint *blueprintPkg.Blueprint `yaml:"blueprint"`
+	// PreExisting holds the state of every resource managed by Blueprint, as loaded from the host
+	// right before it was applied.
+	PreExisting *blueprintPkg.Blueprint `yaml:"pre_existing"`
+	// Original holds the state of every resource managed by Blueprint, as loaded from the host
+	// right before the first time it was applied.
+	Original *blueprintPkg.Blueprint `yaml:"original"`
+	// Applied holds the full state of every resource managed by Blueprint, as loaded from the host
+	// right after it was applied. It is nil for states saved by older versions.
+	Applied *blueprintPkg.Blueprint `yaml:"applied,omitempty"`
+}
+
+// NewRestoreBlueprint returns a Blueprint which restores all resources from lastState which are
+// not declared at blueprint anymore to their original state, in reverse order. lastState may be
+// nil, when no state was saved yet.
+func NewRestoreBlueprint(lastState *State, blueprint *blueprintPkg.Blueprint) *blueprintPkg.Blueprint {
+	restoreBlueprint := &blueprintPkg.Blueprint{}
+	if lastState == nil {
+		return restoreBlueprint
+	}
+
+	dropped := lastState.Blueprint.Subtract(blueprint)
+	for i := len(dropped.Entries) - 1; i >= 0; i-- {
+		entry := dropped.Entries[i]
+		id := entry.Resource.ID()
+		var originalEntry *blueprintPkg.Entry
+		if lastState.Original != nil {
+			originalEntry = lastState.Original.Get(entry.TypeName, id)
+		}
+		if originalEntry == nil && lastState.PreExisting != nil {
+			originalEntry = lastState.PreExisting.Get(entry.TypeName, id)
+		}
+		if originalEntry == nil {
+			continue
+		}
+		restoreBlueprint.Entries = append(restoreBlueprint.Entries, originalEntry)
+	}
+	return restoreBlueprint
+}
+
+// NewState returns the State after blueprint was successfully applied, with its resources
+// previously at preExisting state, and now at applied state. The original state of resources is
+// carried over from lastState, which may be nil, when no state was saved yet.
+func NewState(lastState *State, blueprint, preExisting, applied *blueprintPkg.Blueprint) *State {
+	original := &blueprintPkg.Blueprint{}
+	if lastState != nil && lastState.Original != nil {
+		original.Entries = lastState.Original.Entries
+	}
+	// Resources not managed anymore
+	original = original.Subtract(original.Subtract(blueprint))
+	// Resources managed for the first time
+	original.Entries = append(original.Entries, preExisting.Subtract(original).Entries...)
+
+	return &State{
+		Blueprint:   blueprint,
+		PreExisting: preExisting,
+		Original:    original,
+		Applied:     applied,
+	}
+}
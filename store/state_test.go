@@ -0,0 +1,85 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	blueprintPkg "github.com/fornellas/resonance/blueprint"
+	"github.com/fornellas/resonance/resources"
+)
+
+func newTestBlueprint(resources ...resources.Resource) *blueprintPkg.Blueprint {
+	blueprint := &blueprintPkg.Blueprint{}
+	for _, resource := range resources {
+		blueprint.Entries = append(blueprint.Entries, &blueprintPkg.Entry{
+			TypeName: "APTPackage",
+			Resource: resource,
+		})
+	}
+	return blueprint
+}
+
+func TestNewRestoreBlueprint(t *testing.T) {
+	require.Empty(t, NewRestoreBlueprint(nil, newTestBlueprint()).Entries)
+
+	vimOriginal := &resources.APTPackage{Package: "vim", Absent: true}
+	curlOriginal := &resources.APTPackage{Package: "curl", Version: "1.0"}
+	gitPreExisting := &resources.APTPackage{Package: "git", Absent: true}
+	lastState := &State{
+		Blueprint: newTestBlueprint(
+			&resources.APTPackage{Package: "vim"},
+			&resources.APTPackage{Package: "curl"},
+			&resources.APTPackage{Package: "git"},
+			&resources.APTPackage{Package: "nano"},
+		),
+		PreExisting: newTestBlueprint(
+			&resources.APTPackage{Package: "vim"},
+			&resources.APTPackage{Package: "curl"},
+			gitPreExisting,
+		),
+		Original: newTestBlueprint(vimOriginal, curlOriginal),
+	}
+
+	restoreBlueprint := NewRestoreBlueprint(lastState, newTestBlueprint(
+		&resources.APTPackage{Package: "curl"},
+	))
+	require.Len(t, restoreBlueprint.Entries, 2)
+	require.Equal(t, gitPreExisting, restoreBlueprint.Entries[0].Resource)
+	require.Equal(t, vimOriginal, restoreBlueprint.Entries[1].Resource)
+}
+
+func TestNewState(t *testing.T) {
+	vimOriginal := &resources.APTPackage{Package: "vim", Absent: true}
+	vimPreExisting := &resources.APTPackage{Package: "vim", Version: "1.0"}
+	curlOriginal := &resources.APTPackage{Package: "curl", Absent: true}
+	gitPreExisting := &resources.APTPackage{Package: "git", Version: "2.0"}
+
+	blueprint := newTestBlueprint(&resources.APTPackage{Package: "vim"})
+	preExisting := newTestBlueprint(vimOriginal)
+	applied := newTestBlueprint(&resources.APTPackage{Package: "vim", Version: "2.0"})
+	state := NewState(nil, blueprint, preExisting, applied)
+	require.Equal(t, blueprint, state.Blueprint)
+	require.Equal(t, preExisting, state.PreExisting)
+	require.Equal(t, applied, state.Applied)
+	require.Equal(t, []*blueprintPkg.Entry{preExisting.Entries[0]}, state.Original.Entries)
+
+	lastState := &State{
+		Blueprint: newTestBlueprint(
+			&resources.APTPackage{Package: "vim"},
+			&resources.APTPackage{Package: "curl"},
+		),
+		Original: newTestBlueprint(vimOriginal, curlOriginal),
+	}
+	blueprint = newTestBlueprint(
+		&resources.APTPackage{Package: "vim"},
+		&resources.APTPackage{Package: "git"},
+	)
+	preExisting = newTestBlueprint(vimPreExisting, gitPreExisting)
+	state = NewState(lastState, blueprint, preExisting, applied)
+	require.Equal(t, blueprint, state.Blueprint)
+	require.Equal(t, preExisting, state.PreExisting)
+	require.Len(t, state.Original.Entries, 2)
+	require.Equal(t, vimOriginal, state.Original.Entries[0].Resource)
+	require.Equal(t, gitPreExisting, state.Original.Entries[1].Resource)
+}
@@ -2,15 +2,58 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"io"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Store defines an interface for storage of host state.
 type Store interface {
+	// Lock acquires an exclusive lock over the store, which must be held for the whole session that
+	// changes the host. If the lock is held by another session, returns *LockedError, unless the lock
+	// is stale, in which case it is taken over.
+	Lock(ctx context.Context) error
+
+	// Unlock releases the lock acquired by Lock.
+	Unlock(ctx context.Context) error
+
+	// ForceUnlock releases any lock held over the store, regardless of its holder. It is a no-op
+	// when the store is not locked.
+	ForceUnlock(ctx context.Context) error
+
+	// SaveState persists the State of a successful apply, replacing any previously saved State. It
+	// is also added to the history along with given Metadata, which has its ID assigned. Old
+	// history entries may be purged.
+	SaveState(ctx context.Context, state *State, metadata *Metadata) error
+
+	// LoadState loads the last saved State. If no State was saved yet, returns nil.
+	LoadState(ctx context.Context) (*State, error)
+
+	// ListHistory returns the Metadata of all history entries, oldest first.
+	ListHistory(ctx context.Context) ([]*Metadata, error)
+
+	// LoadHistory loads the history entry with given ID. If it does not exist, returns nil.
+	LoadHistory(ctx context.Context, id int) (*HistoryEntry, error)
+
 	// GetLogWriterCloser returns a io.WriteCloser to be used for logging for the current session,
 	// with given name. On session completion, the object must be closed.
 	// The implementation is responsible for doing log rotation and purge when this function is
 	// called.
 	GetLogWriterCloser(ctx context.Context, name string) (io.WriteCloser, error)
+
+	// ListLogs returns all session logs, oldest first.
+	ListLogs(ctx context.Context) ([]*LogInfo, error)
+
+	// GetLogReadCloser returns a io.ReadCloser with the uncompressed contents of the session log
+	// with given name, as returned by ListLogs. It must be closed after use.
+	GetLogReadCloser(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// decodeYAML decodes data into v, erroring on unknown fields.
+func decodeYAML(data []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(v)
 }